@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
+	"log"
 	"os"
+	"time"
 
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/handlers"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/jobs"
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/middleware"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -24,15 +30,82 @@ func NewAppWithRouter(router *gin.Engine, db *gorm.DB) *App {
 }
 
 func (a *App) initializeRoutes() {
+	keys, err := utils.LoadJWTKeySet()
+	if err != nil {
+		log.Fatal("Failed to load JWT keys: ", err)
+	}
+	oidcProviders, err := handlers.LoadOIDCProviders()
+	if err != nil {
+		log.Fatal("Failed to load OIDC providers: ", err)
+	}
+
 	authRepo := repositories.NewAuthRepository(a.db)
+	refreshRepo := repositories.NewRefreshTokenRepository(a.db)
+	sessionRepo := repositories.NewCachedSessionRepository(repositories.NewSessionRepository(a.db), 30*time.Second)
+	userTokenRepo := repositories.NewUserTokenRepository(a.db)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(a.db)
+	revocations := middleware.NewRevocationCache(repositories.NewRevocationRepository(a.db), 30*time.Second)
+	tokenRepo := repositories.NewPersonalAccessTokenRepository(a.db)
+	adminRepo := repositories.NewAdminRepository(a.db)
+	loginAttempts := repositories.NewLoginAttemptRepository(a.db)
+	if os.Getenv("LOGIN_ATTEMPT_STORE") == "memory" {
+		loginAttempts = repositories.NewMemoryLoginAttemptStore()
+	}
 	taskRepo := repositories.NewTaskRepository(a.db)
 	categoryRepo := repositories.NewCategoryRepository(a.db)
+	oidcRepo := repositories.NewOIDCRepository(a.db)
+	inviteRepo := repositories.NewInviteCodeRepository(a.db)
+	eventRepo := repositories.NewSecurityEventRepository(a.db)
+	reminderRepo := repositories.NewReminderRepository(a.db)
+
+	authHandler := &handlers.AuthHandler{
+		Repo:        authRepo,
+		RefreshRepo: refreshRepo,
+		Revocations: revocations,
+		Sessions:    sessionRepo,
+		UserTokens:  userTokenRepo,
+		Recovery:    recoveryCodeRepo,
+		Throttle:    handlers.NewLoginThrottle(loginAttempts),
+		Keys:        keys,
+		Invites:     inviteRepo,
+		Events:      eventRepo,
 
-	authHandler := &handlers.AuthHandler{Repo: authRepo}
-	taskHandler := handlers.NewTaskHandler(taskRepo)
+		PersonalAccessTokens: tokenRepo,
+	}
+	authHandler.Authenticator, err = handlers.LoadAuthenticator(authRepo)
+	if err != nil {
+		log.Fatal("Failed to configure authentication: ", err)
+	}
+	authHandler.Registration, err = handlers.LoadRegistrationPolicy()
+	if err != nil {
+		log.Fatal("Failed to configure registration: ", err)
+	}
+	sessionHandler := handlers.NewSessionHandler(sessionRepo, refreshRepo)
+	tokenHandler := handlers.NewTokenHandler(tokenRepo, authHandler)
+	adminHandler := handlers.NewAdminHandler(adminRepo, authHandler)
+	oidcHandler := handlers.NewOIDCHandler(oidcProviders, oidcRepo, authHandler)
+	taskHandler := handlers.NewTaskHandler(taskRepo, authRepo, reminderRepo)
+	reminderHandler := handlers.NewReminderHandler(reminderRepo, taskRepo)
 	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
+	profileHandler := handlers.NewProfileHandler(authRepo, categoryRepo)
+	exportHandler := handlers.NewExportHandler(authRepo, taskRepo, reminderRepo, sessionRepo, tokenRepo, eventRepo)
+	jwksHandler := handlers.NewJWKSHandler(keys)
+	scimHandler := handlers.NewSCIMHandler(authHandler)
+
+	authConfig := middleware.AuthConfig{
+		Keys:                 keys,
+		Revocations:          revocations,
+		Sessions:             sessionRepo,
+		PersonalAccessTokens: tokenRepo,
+	}
+
+	rateLimits := middleware.NewMemoryRateLimitStore()
+
+	go jobs.RotateJWTKeys(context.Background(), keys, time.Minute)
+	go jobs.PurgeDeletedAccounts(context.Background(), authRepo, utils.GetAccountDeletionGracePeriod(), time.Hour)
+	go jobs.SendDueReminders(context.Background(), reminderRepo, 30*time.Second, 5*time.Minute)
 
-	SetupRoutes(a.Router, authHandler, taskHandler, categoryHandler)
+	SetupRoutes(a.Router, authConfig, rateLimits, middleware.LoadRateLimits(), os.Getenv("SCIM_TOKEN"), authHandler, oidcHandler, sessionHandler, tokenHandler, adminHandler, profileHandler, exportHandler, jwksHandler, scimHandler, taskHandler, reminderHandler, categoryHandler)
 }
 
 func (a *App) Run() {
@@ -2,6 +2,7 @@ package api
 
 import (
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/handlers"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/middleware"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -9,29 +10,134 @@ import (
 )
 
 func SetupRoutes(router *gin.Engine,
+	authConfig middleware.AuthConfig,
+	rateLimits middleware.RateLimitStore,
+	limits middleware.RateLimits,
+	scimToken string,
 	authHandler *handlers.AuthHandler,
+	oidcHandler *handlers.OIDCHandler,
+	sessionHandler *handlers.SessionHandler,
+	tokenHandler *handlers.TokenHandler,
+	adminHandler *handlers.AdminHandler,
+	profileHandler *handlers.ProfileHandler,
+	exportHandler *handlers.ExportHandler,
+	jwksHandler *handlers.JWKSHandler,
+	scimHandler *handlers.SCIMHandler,
 	taskHandler *handlers.TaskHandler,
+	reminderHandler *handlers.ReminderHandler,
 	categoryHandler *handlers.CategoryHandler) {
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+	router.GET("/.well-known/openid-configuration", jwksHandler.GetOpenIDConfiguration)
 
-	auth := router.Group("/api/v1/auth")
+	auth := router.Group("/api/v1/auth",
+		middleware.RateLimit(rateLimits, "auth", limits.Auth, middleware.KeyByIP))
 	{
-		auth.POST("/register", authHandler.Register)
+		// Endpoints that send email get a much stricter limit, with a
+		// separate quota for each so one flow cannot use up the others.
+		sendsEmail := func(name string) gin.HandlerFunc {
+			return middleware.RateLimit(rateLimits, "auth-email-"+name, limits.AuthEmail, middleware.KeyByIP)
+		}
+
+		auth.POST("/register", sendsEmail("register"), authHandler.Register)
 		auth.POST("/login", authHandler.Login)
-		auth.POST("/forgot-password", authHandler.ForgotPassword)
+		auth.POST("/refresh", authHandler.Refresh)
+		auth.POST("/forgot-password", sendsEmail("forgot-password"), authHandler.ForgotPassword)
 		auth.POST("/reset-password", authHandler.ResetPassword)
 		auth.GET("/verify-email", authHandler.VerifyEmail)
+		auth.POST("/resend-verification", sendsEmail("resend-verification"), authHandler.ResendVerification)
+		auth.POST("/confirm-email", authHandler.ConfirmEmailChange)
+		auth.POST("/mfa/verify", authHandler.VerifyMFA)
+		auth.POST("/magic-link", sendsEmail("magic-link"), authHandler.RequestMagicLink)
+		auth.POST("/magic-link/consume", authHandler.ConsumeMagicLink)
+		auth.GET("/oidc/:provider/start", oidcHandler.StartLogin)
+		auth.GET("/oidc/:provider/callback", oidcHandler.Callback)
+	}
+
+	scim := router.Group("/scim/v2", middleware.RequireProvisioningToken(scimToken))
+	{
+		scim.GET("/Users", scimHandler.GetUsers)
+		scim.POST("/Users", scimHandler.CreateUser)
+		scim.GET("/Users/:id", scimHandler.GetUser)
+		scim.PATCH("/Users/:id", scimHandler.PatchUser)
+		scim.DELETE("/Users/:id", scimHandler.DeleteUser)
 	}
 
-	api := router.Group("/api/v1").Use(middleware.AuthMiddleware())
+	api := router.Group("/api/v1", middleware.AuthMiddleware(authConfig),
+		middleware.RateLimit(rateLimits, "api", limits.API, middleware.KeyByUser))
 	{
-		api.GET("/tasks", taskHandler.GetTasks)
-		api.POST("/tasks", taskHandler.CreateTask)
-		api.PUT("/tasks/:id", taskHandler.UpdateTask)
-		api.DELETE("/tasks/:id", taskHandler.DeleteTask)
-		api.PATCH("/tasks/:id/complete", taskHandler.ToggleTask)
+		account := api.Group("", middleware.RequireSessionAuth())
+		{
+			account.POST("/auth/logout", authHandler.Logout)
+			account.POST("/auth/logout-all", authHandler.LogoutAll)
+			account.POST("/auth/mfa/totp/setup", authHandler.SetupTOTP)
+			account.POST("/auth/mfa/totp/confirm", authHandler.ConfirmTOTP)
+			account.POST("/auth/mfa/totp/disable", authHandler.DisableTOTP)
+			account.POST("/auth/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+			account.GET("/me", profileHandler.GetProfile)
+			account.PATCH("/me", profileHandler.UpdateProfile)
+			account.DELETE("/me", authHandler.DeleteAccount)
+			account.POST("/me/export",
+				middleware.RateLimit(rateLimits, "account-export", limits.Account, middleware.KeyByUser),
+				exportHandler.ExportData)
+			account.PUT("/me/password", authHandler.ChangePassword)
+			account.POST("/me/email",
+				middleware.RateLimit(rateLimits, "account-email", limits.Account, middleware.KeyByUser),
+				authHandler.RequestEmailChange)
+			account.GET("/me/security-events", authHandler.GetSecurityEvents)
+
+			account.GET("/sessions", sessionHandler.GetSessions)
+			account.DELETE("/sessions/:id", sessionHandler.DeleteSession)
+
+			account.GET("/tokens", tokenHandler.GetTokens)
+			account.POST("/tokens", tokenHandler.CreateToken)
+			account.PUT("/tokens/:id", tokenHandler.UpdateToken)
+			account.DELETE("/tokens/:id", tokenHandler.DeleteToken)
+		}
+
+		admin := api.Group("/admin", middleware.RequireSessionAuth(),
+			middleware.RequireRole(string(models.RoleAdmin), string(models.RoleSupport)))
+		{
+			adminOnly := middleware.RequireRole(string(models.RoleAdmin))
+
+			admin.GET("/users", adminHandler.GetUsers)
+			admin.GET("/users/:id", adminHandler.GetUser)
+			admin.POST("/users/:id/verify", adminHandler.VerifyUser)
+			admin.POST("/users/:id/force-password-reset", adminHandler.ForcePasswordReset)
+			admin.POST("/users/:id/suspend", adminOnly, adminHandler.SuspendUser)
+			admin.POST("/users/:id/unsuspend", adminOnly, adminHandler.UnsuspendUser)
+			admin.PUT("/users/:id/role", adminOnly, adminHandler.UpdateUserRole)
+
+			admin.GET("/security-events", adminHandler.GetSecurityEvents)
+
+			admin.GET("/invites", adminOnly, adminHandler.GetInviteCodes)
+			admin.POST("/invites", adminOnly, adminHandler.CreateInviteCode)
+			admin.DELETE("/invites/:id", adminOnly, adminHandler.DeleteInviteCode)
+		}
+
+		tasksRead := middleware.RequireScope(models.ScopeTasksRead)
+		tasksWrite := middleware.RequireScope(models.ScopeTasksWrite)
+
+		api.GET("/tasks", tasksRead, taskHandler.GetTasks)
+		api.POST("/tasks", tasksWrite, taskHandler.CreateTask)
+		api.PUT("/tasks/:id", tasksWrite, taskHandler.UpdateTask)
+		api.DELETE("/tasks/:id", tasksWrite, taskHandler.DeleteTask)
+		api.PATCH("/tasks/:id/complete", tasksWrite, taskHandler.ToggleTask)
+
+		api.GET("/tasks/:id/subtasks", tasksRead, taskHandler.GetSubtasks)
+		api.POST("/tasks/:id/subtasks", tasksWrite, taskHandler.CreateSubtask)
+		api.PUT("/tasks/:id/subtasks/:subtaskId", tasksWrite, taskHandler.UpdateSubtask)
+		api.DELETE("/tasks/:id/subtasks/:subtaskId", tasksWrite, taskHandler.DeleteSubtask)
+		api.PATCH("/tasks/:id/subtasks/:subtaskId/complete", tasksWrite, taskHandler.ToggleSubtask)
+
+		api.GET("/tasks/:id/reminders", tasksRead, reminderHandler.GetReminders)
+		api.POST("/tasks/:id/reminders", tasksWrite, reminderHandler.CreateReminder)
+		api.DELETE("/tasks/:id/reminders/:reminderId", tasksWrite, reminderHandler.DeleteReminder)
+		api.POST("/reminders/:id/snooze", tasksWrite, reminderHandler.SnoozeReminder)
+		api.POST("/reminders/:id/dismiss", tasksWrite, reminderHandler.DismissReminder)
 
-		api.GET("/categories", categoryHandler.GetCategories)
+		api.GET("/categories", middleware.RequireScope(models.ScopeCategoriesRead), categoryHandler.GetCategories)
 	}
 }
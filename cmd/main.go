@@ -13,7 +13,9 @@ package main
 import (
 	"log"
 	"os"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/cmd/api"
 	_ "github.com/A4GOD-AMHG/sylcot-go-gin-backend/docs"
@@ -46,12 +48,31 @@ func main() {
 		log.Fatal("Failed to seed categories: ", err)
 	}
 
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		promoted, err := models.PromoteAdmin(db, adminEmail)
+		if err != nil {
+			log.Fatal("Failed to promote admin: ", err)
+		}
+		if promoted {
+			log.Printf("Promoted %s to admin", adminEmail)
+		}
+	}
+
+	if err := models.RegisterBindingValidations(); err != nil {
+		log.Fatal("Failed to register validations: ", err)
+	}
+
 	router := gin.Default()
+	// Client IPs drive the login lockout, rate limits and audit log, so
+	// X-Forwarded-For is only honored when sent by a known proxy.
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES: ", err)
+	}
 	corsConfig := cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -61,3 +82,15 @@ func main() {
 
 	app.Run()
 }
+
+// trustedProxies reads the comma separated IPs and CIDRs of TRUSTED_PROXIES.
+// Without any, the client IP is always the address of the connection.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const emailChangeTokenTTL = 24 * time.Hour
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" example:"Password*1"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,password" example:"NewPassword*2"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email,max=255" example:"new@example.com"`
+	Password string `json:"password" example:"Password*1"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the password of the authenticated user. Every other session is logged out; the current one stays active. Accounts created through single sign-on or a magic link have no password: they omit current_password to set their first one, which needs a recent login.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param password body ChangePasswordRequest true "Current and new password"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string,details=object}
+// @Failure 401 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/me/password [put]
+func (ah *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": models.GetValidationMessages(err),
+		})
+		return
+	}
+
+	user, ok := ah.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !ah.checkCurrentPassword(c, user, req.CurrentPassword) {
+		return
+	}
+
+	if user.Password != "" && req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must be different from the current one"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error encrypting password"})
+		return
+	}
+
+	user.Password = string(hashedPassword)
+	user.PasswordResetRequired = false
+	if err := ah.Repo.UpdateUser(user, "password", "password_reset_required"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update password"})
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventPasswordChanged, user, "")
+
+	if err := ah.UserTokens.InvalidateUserTokens(user.ID, models.TokenPurposePasswordReset); err != nil {
+		log.Printf("Could not invalidate reset tokens for user %d: %v", user.ID, err)
+	}
+
+	if err := ah.endOtherSessions(user.ID, c.GetString("sessionID")); err != nil {
+		log.Printf("Could not end other sessions of user %d after password change: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password updated, but other sessions could not be logged out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+}
+
+// RequestEmailChange godoc
+// @Summary Change email address
+// @Description Start changing the email of the authenticated user. A confirmation link is sent to the new address and a notice to the current one; the email only changes once the link is used. Accounts without a password omit it and need a recent login instead.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param email body ChangeEmailRequest true "New email and current password"
+// @Security ApiKeyAuth
+// @Success 202 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/me/email [post]
+func (ah *AuthHandler) RequestEmailChange(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+		return
+	}
+	newEmail := strings.TrimSpace(req.NewEmail)
+
+	user, ok := ah.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !ah.checkCurrentPassword(c, user, req.Password) {
+		return
+	}
+
+	if strings.EqualFold(newEmail, user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "That is already your email address"})
+		return
+	}
+
+	if !ah.Registration.AllowsEmail(newEmail) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This email domain is not allowed"})
+		return
+	}
+
+	if _, err := ah.Repo.FindByEmail(newEmail); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User with that email already registered"})
+		return
+	} else if !errors.Is(err, repositories.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	token, err := ah.issueUserTokenWithData(user.ID, models.TokenPurposeEmailChange, emailChangeTokenTTL, newEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate confirmation token"})
+		return
+	}
+
+	confirmLink := os.Getenv("FRONT_URL") + "/auth/confirm-email?token=" + token
+	if err := utils.SendEmailChangeConfirmation(newEmail, user.Locale, confirmLink); err != nil {
+		log.Printf("Could not send email change confirmation to %s: %v", newEmail, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send confirmation email"})
+		return
+	}
+
+	if err := utils.SendEmailChangeNotice(user.Email, user.Locale, newEmail); err != nil {
+		log.Printf("Could not send email change notice to %s: %v", user.Email, err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new address"})
+}
+
+// ConfirmEmailChange godoc
+// @Summary Confirm email change
+// @Description Finish an email change with the token sent to the new address. The new address counts as verified. Every session is logged out and all tokens are revoked, so the user has to log in again with the new address.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param token body ConfirmEmailChangeRequest true "Confirmation token"
+// @Success 200 {object} object{message=string,user=models.UserDTO}
+// @Failure 400 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/confirm-email [post]
+func (ah *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	var req ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	userToken, err := ah.UserTokens.ConsumeUserToken(utils.HashToken(req.Token), models.TokenPurposeEmailChange)
+	if err != nil {
+		if errors.Is(err, repositories.ErrTokenNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	user, err := ah.Repo.FindByID(userToken.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	previousEmail := user.Email
+	user.Email = userToken.Data
+	user.IsVerified = true
+	if err := ah.Repo.UpdateUser(user, "email", "is_verified"); err != nil {
+		if utils.IsDuplicateError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User with that email already registered"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update email"})
+		}
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventEmailChanged, user, previousEmail)
+
+	if err := ah.revokeAllTokens(user.ID); err != nil {
+		log.Printf("Could not revoke tokens for user %d after email change: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Email updated, but sessions could not be logged out"})
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventTokenRevoked, user, "email_change")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email updated successfully", "user": user.ToDTO()})
+}
+
+// checkCurrentPassword confirms a sensitive change with the password of the
+// authenticated user, writing the error response itself. Wrong passwords
+// count as failed logins, so a stolen session cannot be used to guess the
+// password without running into the lockout. Users without a password
+// must instead have logged in to the current session recently.
+func (ah *AuthHandler) checkCurrentPassword(c *gin.Context, user *models.User, password string) bool {
+	if user.Password == "" {
+		return ah.checkRecentLogin(c, user)
+	}
+
+	if ah.rejectThrottledLogin(c, user.Email, user) {
+		return false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		ah.failLogin(c, user.Email, user, loginFailureInvalidCredentials, http.StatusUnauthorized, "Invalid password")
+		return false
+	}
+
+	if err := ah.Throttle.recordSuccess(user.Email); err != nil {
+		log.Printf("Could not reset login attempts for %s: %v", user.Email, err)
+	}
+	return true
+}
+
+func (ah *AuthHandler) checkRecentLogin(c *gin.Context, user *models.User) bool {
+	session, err := ah.Sessions.GetSession(c.GetString("sessionID"), user.ID)
+	if err != nil && !errors.Is(err, repositories.ErrSessionNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return false
+	}
+	if session == nil || time.Since(session.CreatedAt) > utils.GetReauthWindow() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Log in again to confirm this change"})
+		return false
+	}
+	return true
+}
+
+// endOtherSessions logs out every session of the user except keepID and
+// revokes their refresh tokens.
+func (ah *AuthHandler) endOtherSessions(userID uint, keepID string) error {
+	ids, err := ah.Sessions.DeleteUserSessions(userID, keepID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := ah.RefreshRepo.RevokeFamily(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"Password*1"`
+}
+
+// DeleteAccount godoc
+// @Summary Delete account
+// @Description Schedule the authenticated user's account for deletion. Every session is logged out and the account is permanently deleted with all of its data once the grace period (30 days by default) is over. Logging in before then cancels the deletion. Accounts without a password omit it and need a recent login instead.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param confirmation body DeleteAccountRequest true "Current password"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string,purge_at=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/me [delete]
+func (ah *AuthHandler) DeleteAccount(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	user, ok := ah.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !ah.checkCurrentPassword(c, user, req.Password) {
+		return
+	}
+
+	now := time.Now()
+	user.DeletedAt = &now
+	if err := ah.Repo.UpdateUser(user, "deleted_at"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete account"})
+		return
+	}
+
+	if err := ah.revokeAllTokens(user.ID); err != nil {
+		log.Printf("Could not revoke tokens of deleted user %d: %v", user.ID, err)
+	}
+
+	ah.recordEvent(c, models.SecurityEventTokenRevoked, user, "account_deletion")
+
+	purgeAt := now.Add(utils.GetAccountDeletionGracePeriod())
+	if err := utils.SendAccountDeletionScheduledEmail(user.Email, user.Locale, utils.FormatEmailTime(purgeAt, user.Location())); err != nil {
+		log.Printf("Could not send account deletion email to %s: %v", user.Email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account scheduled for deletion", "purge_at": purgeAt})
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultUsersPageSize = 20
+	maxUsersPageSize     = 100
+)
+
+// AdminHandler serves the user administration endpoints. Account changes go
+// through the AuthHandler so they revoke tokens and send emails the same way
+// the self-service flows do.
+type AdminHandler struct {
+	repo repositories.AdminRepository
+	auth *AuthHandler
+}
+
+func NewAdminHandler(repo repositories.AdminRepository, auth *AuthHandler) *AdminHandler {
+	return &AdminHandler{repo: repo, auth: auth}
+}
+
+type UpdateRoleRequest struct {
+	Role models.Role `json:"role" binding:"required" example:"support"`
+}
+
+// GetUsers godoc
+// @Summary List users
+// @Description List and search users with their task counts. Available to admin and support roles.
+// @Tags admin
+// @Produce json
+// @Param q query string false "Search in name and email"
+// @Param role query string false "Filter by role" Enums(user, support, admin)
+// @Param status query string false "Filter by account status" Enums(active, suspended, unverified)
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size (max 100)" default(20)
+// @Security ApiKeyAuth
+// @Success 200 {object} object{users=[]models.AdminUserDTO,total=int,page=int,page_size=int}
+// @Failure 400 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/users [get]
+func (ah *AdminHandler) GetUsers(c *gin.Context) {
+	filter := repositories.UserFilter{
+		Query:  c.Query("q"),
+		Role:   models.Role(c.Query("role")),
+		Status: c.Query("status"),
+	}
+
+	if filter.Role != "" && !models.IsValidRole(filter.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	switch filter.Status {
+	case "", repositories.UserStatusActive, repositories.UserStatusSuspended, repositories.UserStatusUnverified:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultUsersPageSize)))
+	if filter.PageSize < 1 || filter.PageSize > maxUsersPageSize {
+		filter.PageSize = defaultUsersPageSize
+	}
+
+	users, total, err := ah.repo.SearchUsers(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching users"})
+		return
+	}
+
+	userIDs := make([]uint, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+	counts, err := ah.repo.GetTaskCounts(userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching task counts"})
+		return
+	}
+
+	userDTOs := []*models.AdminUserDTO{}
+	for _, user := range users {
+		userDTOs = append(userDTOs, user.ToAdminDTO(counts[user.ID]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":     userDTOs,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
+	})
+}
+
+// GetUser godoc
+// @Summary Get a user
+// @Description Get a user with their task counts. Available to admin and support roles.
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.AdminUserDTO
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/users/{id} [get]
+func (ah *AdminHandler) GetUser(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	ah.respondWithUser(c, user)
+}
+
+// VerifyUser godoc
+// @Summary Verify a user's email
+// @Description Mark the user's email as verified without the verification link. Available to admin and support roles.
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.AdminUserDTO
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/users/{id}/verify [post]
+func (ah *AdminHandler) VerifyUser(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := ah.repo.SetVerified(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify user"})
+		return
+	}
+
+	if err := ah.auth.UserTokens.InvalidateUserTokens(user.ID, models.TokenPurposeEmailVerification); err != nil {
+		log.Printf("Could not invalidate verification tokens for user %d: %v", user.ID, err)
+	}
+
+	ah.auth.recordEvent(c, models.SecurityEventEmailVerified, user, "admin")
+
+	user.IsVerified = true
+	ah.respondWithUser(c, user)
+}
+
+// SuspendUser godoc
+// @Summary Suspend a user
+// @Description Block the user from logging in and revoke all of their sessions and tokens, including personal access tokens. Admin only.
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.AdminUserDTO
+// @Failure 400 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/users/{id}/suspend [post]
+func (ah *AdminHandler) SuspendUser(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	if isCurrentUser(c, user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot suspend your own account"})
+		return
+	}
+
+	if err := ah.repo.SetSuspended(user.ID, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not suspend user"})
+		return
+	}
+
+	if err := ah.auth.revokeAllTokens(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke tokens"})
+		return
+	}
+
+	ah.auth.recordEvent(c, models.SecurityEventTokenRevoked, user, "suspension")
+
+	ah.reloadUser(c, user.ID)
+}
+
+// UnsuspendUser godoc
+// @Summary Lift a suspension
+// @Description Allow a suspended user to log in again. Tokens revoked by the suspension stay revoked. Admin only.
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.AdminUserDTO
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/users/{id}/unsuspend [post]
+func (ah *AdminHandler) UnsuspendUser(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := ah.repo.SetSuspended(user.ID, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not unsuspend user"})
+		return
+	}
+
+	user.SuspendedAt = nil
+	ah.respondWithUser(c, user)
+}
+
+// ForcePasswordReset godoc
+// @Summary Force a password reset
+// @Description Log the user out everywhere, block password logins until they reset their password and email them a reset link. Available to admin and support roles; support can only reset regular users.
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.AdminUserDTO
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/users/{id}/force-password-reset [post]
+func (ah *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	if !canManage(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to manage this user"})
+		return
+	}
+
+	if err := ah.repo.RequirePasswordReset(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update user"})
+		return
+	}
+
+	if err := ah.auth.revokeAllTokens(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke tokens"})
+		return
+	}
+
+	ah.auth.recordEvent(c, models.SecurityEventTokenRevoked, user, "forced_password_reset")
+
+	if err := ah.auth.sendPasswordResetEmail(user); err != nil {
+		log.Printf("Error sending reset email to %s: %v", user.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send reset email"})
+		return
+	}
+
+	ah.auth.recordEvent(c, models.SecurityEventPasswordResetRequested, user, "admin")
+
+	ah.reloadUser(c, user.ID)
+}
+
+// UpdateUserRole godoc
+// @Summary Change a user's role
+// @Description Change the role of a user. Their sessions are ended so new tokens carry the new role. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role body UpdateRoleRequest true "New role"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.AdminUserDTO
+// @Failure 400 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/users/{id}/role [put]
+func (ah *AdminHandler) UpdateUserRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of: user, support, admin"})
+		return
+	}
+
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	if isCurrentUser(c, user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	if user.Role == req.Role {
+		ah.respondWithUser(c, user)
+		return
+	}
+
+	if err := ah.repo.SetRole(user.ID, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update role"})
+		return
+	}
+
+	if err := ah.auth.revokeAllTokens(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke tokens"})
+		return
+	}
+
+	ah.auth.recordEvent(c, models.SecurityEventRoleChanged, user, string(user.Role)+" -> "+string(req.Role))
+
+	user.Role = req.Role
+	ah.respondWithUser(c, user)
+}
+
+// findUser loads the user named by the id path parameter, writing the error
+// response itself.
+func (ah *AdminHandler) findUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	user, err := ah.auth.Repo.FindByID(uint(id))
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+func (ah *AdminHandler) reloadUser(c *gin.Context, id uint) {
+	user, err := ah.auth.Repo.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+		return
+	}
+	ah.respondWithUser(c, user)
+}
+
+func (ah *AdminHandler) respondWithUser(c *gin.Context, user *models.User) {
+	counts, err := ah.repo.GetTaskCounts([]uint{user.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching task counts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToAdminDTO(counts[user.ID]))
+}
+
+// canManage reports whether the caller may take over the account of user.
+// Admins can manage anyone; other staff only users with a lower role.
+func canManage(c *gin.Context, user *models.User) bool {
+	role := models.Role(c.GetString("userRole"))
+	return role == models.RoleAdmin || role.Outranks(user.Role)
+}
+
+func isCurrentUser(c *gin.Context, user *models.User) bool {
+	userID, _ := c.Get("userID")
+	return userID.(int) == int(user.ID)
+}
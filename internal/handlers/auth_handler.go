@@ -6,6 +6,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/A4GOD-AMHG/sylcot-go-gin-backend/docs"
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
@@ -17,38 +20,66 @@ import (
 )
 
 type AuthHandler struct {
-	Repo repositories.AuthRepository
+	Repo        repositories.AuthRepository
+	RefreshRepo repositories.RefreshTokenRepository
+	Revocations repositories.RevocationRepository
+	Sessions    repositories.SessionRepository
+	UserTokens  repositories.UserTokenRepository
+	Recovery    repositories.RecoveryCodeRepository
+	Throttle    *LoginThrottle
+	Keys        *utils.JWTKeySet
+	// Authenticator checks the credentials of password logins.
+	Authenticator Authenticator
+	// Registration decides who may create an account.
+	Registration RegistrationPolicy
+	Invites      repositories.InviteCodeRepository
+	Events       repositories.SecurityEventRepository
+	// PersonalAccessTokens are revoked along with the other tokens of a user.
+	PersonalAccessTokens repositories.PersonalAccessTokenRepository
 }
 
+const (
+	verificationTokenTTL       = 24 * time.Hour
+	resetTokenTTL              = time.Hour
+	resendVerificationInterval = time.Minute
+)
+
 type RegisterRequest struct {
 	Name     string `json:"name" example:"John Doe"`
 	Email    string `json:"email" example:"user@example.com"`
 	Password string `json:"password" example:"Password*1"`
+	// InviteCode is required while registration is invite-only.
+	InviteCode string `json:"invite_code" example:"sylcot_inv_3q2-7wEXAMPLE"`
 }
 
 // Register godoc
 // @Summary Register new user
-// @Description Create a new user account
+// @Description Create a new user account. Depending on the registration mode, registration may be closed or need an invite code, and only some email domains may be accepted.
 // @Tags authentication
 // @Accept json
 // @Produce json
 // @Param user body RegisterRequest true "Registration data"
 // @Success 201 {object} object{message=string}
 // @Failure 400 {object} object{error=string,details=object}
+// @Failure 403 {object} object{error=string}
 // @Failure 409 {object} object{error=string}
 // @Failure 500 {object} object{error=string}
 // @Router /api/v1/auth/register [post]
 func (ah *AuthHandler) Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	if ah.Registration.Mode == RegistrationClosed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is closed"})
+		return
+	}
+
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request data",
 			"details": map[string]interface{}{},
 		})
 		return
 	}
-
-	log.Println(user)
+	user := models.User{Name: req.Name, Email: req.Email, Password: req.Password}
 
 	if err := user.Validate(); err != nil {
 		validationErrors := models.GetValidationMessages(err)
@@ -59,6 +90,11 @@ func (ah *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if !ah.Registration.AllowsEmail(user.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is not allowed for this email domain"})
+		return
+	}
+
 	_, err := ah.Repo.FindByEmail(user.Email)
 	if err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User with that email already registered"})
@@ -74,15 +110,31 @@ func (ah *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if ah.Registration.Mode == RegistrationInvite {
+		if err := ah.Invites.RedeemInviteCode(req.InviteCode); err != nil {
+			if errors.Is(err, repositories.ErrInviteCodeInvalid) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "A valid invite code is required to register"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			}
+			return
+		}
+	}
+
 	newUser := models.User{
 		Name:       user.Name,
 		Email:      user.Email,
 		Password:   string(hashedPassword),
 		IsVerified: false,
-		Token:      uuid.NewString(),
+		Locale:     requestLocale(c),
 	}
 
 	if err := ah.Repo.CreateUser(&newUser); err != nil {
+		if ah.Registration.Mode == RegistrationInvite {
+			if err := ah.Invites.ReleaseInviteCode(req.InviteCode); err != nil {
+				log.Printf("Could not release invite code: %v", err)
+			}
+		}
 		if errors.Is(err, repositories.ErrUserAlreadyExists) {
 			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		} else {
@@ -91,39 +143,56 @@ func (ah *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	verificationLink := os.Getenv("FRONT_URL") + "/auth/verify-email?token=" + newUser.Token
-	if err := utils.SendVerificationEmail(user.Email, verificationLink); err != nil {
+	ah.recordEvent(c, models.SecurityEventRegistered, &newUser, "password")
+
+	if err := ah.sendVerificationEmail(&newUser); err != nil {
 		log.Printf("Could not send verification email to %s: %v", user.Email, err)
 	}
 
-	fmt.Println(verificationLink)
-
 	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully. Please verify your email."})
 }
 
+// requestLocale picks the first supported language of the Accept-Language
+// header, so emails sent before the user sets a locale are in their language.
+func requestLocale(c *gin.Context) string {
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		language := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		if utils.IsSupportedLocale(language) {
+			return language
+		}
+	}
+	return utils.DefaultLocale
+}
+
 type LoginRequest struct {
-	Email    string `json:"email" example:"user@example.com"`
-	Password string `json:"password" example:"Password*1"`
+	Email      string `json:"email" example:"user@example.com"`
+	Password   string `json:"password" example:"Password*1"`
+	DeviceName string `json:"device_name" example:"John's phone"`
 }
 
 // Login godoc
 // @Summary User login
-// @Description Authenticate user and return JWT token and user info
+// @Description Authenticate user and return a short-lived JWT access token, a refresh token and user info.
+// @Description When two-factor authentication is enabled, only mfa_required and an mfa_token are returned; post the token with a code to /api/v1/auth/mfa/verify to finish logging in.
+// @Description Logging in to an account pending deletion cancels the deletion and sets account_restored.
 // @Tags authentication
 // @Accept json
 // @Produce json
 // @Param credentials body LoginRequest true "Login credentials"
-// @Success 200 {object} object{token=string,user=models.UserDTO}
+// @Success 200 {object} object{token=string,refresh_token=string,expires_in=int,user=models.UserDTO,account_restored=bool,mfa_required=bool,mfa_token=string}
 // @Failure 400 {object} object{error=string}
 // @Failure 401 {object} object{error=string}
 // @Failure 403 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
 // @Failure 500 {object} object{error=string}
 // @Router /api/v1/auth/login [post]
 // Ejemplo de request:
 func (ah *AuthHandler) Login(c *gin.Context) {
 	var loginData struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"device_name" binding:"max=100"`
 	}
 
 	if err := c.ShouldBindJSON(&loginData); err != nil {
@@ -131,35 +200,297 @@ func (ah *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := ah.Repo.FindByEmail(loginData.Email)
+	if ah.rejectThrottledLogin(c, loginData.Email, nil) {
+		return
+	}
+
+	// Directory backends sync the account on login, so keep what it was to
+	// notice changes they make.
+	previous, err := ah.Repo.FindByEmail(loginData.Email)
+	if err != nil && !errors.Is(err, repositories.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	user, err := ah.Authenticator.Authenticate(loginData.Email, loginData.Password)
 	if err != nil {
-		if errors.Is(err, repositories.ErrUserNotFound) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		if errors.Is(err, ErrInvalidCredentials) {
+			ah.failLogin(c, loginData.Email, user, loginFailureInvalidCredentials, http.StatusUnauthorized, "Invalid email or password")
 		} else {
+			log.Printf("Could not authenticate %s: %v", loginData.Email, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		}
 		return
 	}
 
+	if !ah.applySyncedChanges(c, previous, user) {
+		return
+	}
+
 	if !user.IsVerified {
+		ah.recordLoginFailure(c, loginData.Email, user, loginFailureUnverified)
 		c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email first"})
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	if err := ah.Throttle.recordSuccess(loginData.Email); err != nil {
+		log.Printf("Could not reset login attempts for %s: %v", loginData.Email, err)
+	}
+
+	if !ah.checkAccountStatus(c, user) {
 		return
 	}
 
-	jwtToken, err := utils.GenerateJWT(user.Email, int(user.ID))
+	ah.finishLogin(c, user, loginData.DeviceName, "password")
+}
+
+// applySyncedChanges ends the sessions of an account whose role the
+// authenticator changed, since their tokens carry the previous role, or which
+// it verified, since whoever registered the address never proved owning it.
+// It writes the error response itself.
+func (ah *AuthHandler) applySyncedChanges(c *gin.Context, previous, user *models.User) bool {
+	if previous == nil || (previous.Role == user.Role && previous.IsVerified == user.IsVerified) {
+		return true
+	}
+
+	if err := ah.revokeAllTokens(user.ID); err != nil {
+		log.Printf("Could not revoke tokens of user %d after directory sync: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return false
+	}
+
+	if previous.Role != user.Role {
+		ah.recordEvent(c, models.SecurityEventRoleChanged, user, string(previous.Role)+" -> "+string(user.Role))
+	}
+	if !previous.IsVerified && user.IsVerified {
+		ah.recordEvent(c, models.SecurityEventEmailVerified, user, "directory")
+	}
+	return true
+}
+
+// checkAccountStatus rejects logins to accounts an administrator has
+// suspended or flagged for a password reset, writing the response itself.
+func (ah *AuthHandler) checkAccountStatus(c *gin.Context, user *models.User) bool {
+	if user.IsSuspended() {
+		ah.recordLoginFailure(c, user.Email, user, loginFailureSuspended)
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been suspended"})
+		return false
+	}
+	if user.PasswordResetRequired {
+		ah.recordLoginFailure(c, user.Email, user, loginFailurePasswordResetPending)
+		c.JSON(http.StatusForbidden, gin.H{"error": "A password reset is required. Check your email for the reset link"})
+		return false
+	}
+	return true
+}
+
+// finishLogin asks for the second factor when the user has enabled one, and
+// completes the login otherwise. method names how the user authenticated in
+// the audit log.
+func (ah *AuthHandler) finishLogin(c *gin.Context, user *models.User, deviceName, method string) {
+	if user.TOTPEnabled {
+		mfaToken, err := ah.Keys.GenerateMFAToken(int(user.ID), deviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate JWT Token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	ah.completeLogin(c, user, deviceName, method)
+}
+
+// completeLogin starts a session for an authenticated user and responds with
+// its tokens. Logging in to an account pending deletion restores it.
+func (ah *AuthHandler) completeLogin(c *gin.Context, user *models.User, deviceName, method string) {
+	restored := user.DeletedAt != nil
+	if restored {
+		user.DeletedAt = nil
+		if err := ah.Repo.UpdateUser(user, "deleted_at"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not restore account"})
+			return
+		}
+	}
+
+	session, err := ah.startSession(c, user, deviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create session"})
+		return
+	}
+
+	jwtToken, refreshToken, err := ah.issueTokens(user, session.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate JWT Token"})
 		return
 	}
 
+	ah.recordEvent(c, models.SecurityEventLoginSucceeded, user, method)
+
 	userDTO := user.ToDTO()
 
-	c.JSON(http.StatusOK, gin.H{"token": jwtToken, "user": userDTO})
+	c.JSON(http.StatusOK, gin.H{
+		"token":            jwtToken,
+		"refresh_token":    refreshToken,
+		"expires_in":       int(utils.GetJWTExpiration().Seconds()),
+		"user":             userDTO,
+		"account_restored": restored,
+	})
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once; presenting an already used token revokes every token issued from the same login.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param refresh body RefreshRequest true "Refresh token"
+// @Success 200 {object} object{token=string,refresh_token=string,expires_in=int}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/refresh [post]
+func (ah *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	stored, err := ah.RefreshRepo.FindByHash(utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	if stored.RevokedAt != nil || stored.IsExpired() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	rotated := false
+	if stored.RotatedAt == nil {
+		rotated, err = ah.RefreshRepo.MarkRotated(stored.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+	}
+
+	if !rotated {
+		log.Printf("Refresh token reuse detected for user %d, ending session %s", stored.UserID, stored.FamilyID)
+		if err := ah.endSession(stored.FamilyID, stored.UserID); err != nil {
+			log.Printf("Could not end session %s: %v", stored.FamilyID, err)
+		}
+		ah.recordEvent(c, models.SecurityEventTokenRevoked, &models.User{ID: stored.UserID}, "refresh_token_reuse")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	active, err := ah.Sessions.TouchSession(stored.FamilyID, stored.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !active {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	user, err := ah.Repo.FindByID(stored.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	if user.IsSuspended() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been suspended"})
+		return
+	}
+
+	jwtToken, refreshToken, err := ah.issueTokens(user, stored.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate JWT Token"})
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventTokenRefreshed, user, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         jwtToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(utils.GetJWTExpiration().Seconds()),
+	})
+}
+
+// startSession records a new login of the user from the current client.
+func (ah *AuthHandler) startSession(c *gin.Context, user *models.User, deviceName string) (*models.Session, error) {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	session := models.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IP:         c.ClientIP(),
+	}
+	if err := ah.Sessions.CreateSession(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// endSession deletes a session and revokes the refresh tokens issued for it.
+// Access tokens of the session are rejected by the middleware once the
+// session is gone.
+func (ah *AuthHandler) endSession(sessionID string, userID uint) error {
+	if err := ah.Sessions.DeleteSession(sessionID, userID); err != nil && !errors.Is(err, repositories.ErrSessionNotFound) {
+		return err
+	}
+	return ah.RefreshRepo.RevokeFamily(sessionID)
+}
+
+// issueTokens signs an access token for the session and stores a new refresh
+// token in the session's family. Refreshing keeps the family of the token
+// being rotated.
+func (ah *AuthHandler) issueTokens(user *models.User, sessionID string) (string, string, error) {
+	jwtToken, err := ah.Keys.GenerateJWT(user.Email, int(user.ID), sessionID, string(user.Role))
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(utils.GetRefreshTokenExpiration()),
+	}
+	if err := ah.RefreshRepo.CreateRefreshToken(&record); err != nil {
+		return "", "", err
+	}
+
+	return jwtToken, refreshToken, nil
 }
 
 // VerifyEmail godoc
@@ -180,10 +511,20 @@ func (ah *AuthHandler) VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	user, err := ah.Repo.FindByToken(token)
+	userToken, err := ah.UserTokens.ConsumeUserToken(utils.HashToken(token), models.TokenPurposeEmailVerification)
 	if err != nil {
 		if errors.Is(err, repositories.ErrTokenNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid token"})
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	user, err := ah.Repo.FindByID(userToken.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid or expired token"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		}
@@ -191,18 +532,66 @@ func (ah *AuthHandler) VerifyEmail(c *gin.Context) {
 	}
 
 	user.IsVerified = true
-	user.Token = ""
 
-	if err := ah.Repo.UpdateUser(user); err != nil {
+	if err := ah.Repo.UpdateUser(user, "is_verified"); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating the user"})
 		return
 	}
 
+	ah.recordEvent(c, models.SecurityEventEmailVerified, user, "link")
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": fmt.Sprintf("User with email %s verified successfully", user.Email),
 	})
 }
 
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new email verification link to an account that is not verified yet. Previous links stop working. Requests for the same account are limited to one per minute.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param email body object{email=string} true "Registered email address"
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/resend-verification [post]
+func (ah *AuthHandler) ResendVerification(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+		return
+	}
+
+	user, err := ah.Repo.FindByEmail(req.Email)
+	if err != nil || user.IsVerified {
+		c.JSON(http.StatusOK, gin.H{"message": "If an unverified account exists, a verification link has been sent"})
+		return
+	}
+
+	lastIssuedAt, err := ah.UserTokens.LastIssuedAt(user.ID, models.TokenPurposeEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if wait := time.Until(lastIssuedAt.Add(resendVerificationInterval)); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another verification email"})
+		return
+	}
+
+	if err := ah.sendVerificationEmail(user); err != nil {
+		log.Printf("Could not send verification email to %s: %v", user.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an unverified account exists, a verification link has been sent"})
+}
+
 // ForgotPassword godoc
 // @Summary Request password reset
 // @Description Send password reset instructions to email
@@ -212,6 +601,7 @@ func (ah *AuthHandler) VerifyEmail(c *gin.Context) {
 // @Param email body object{email=string} true "Registered email address"
 // @Success 200 {object} object{message=string}
 // @Failure 400 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
 // @Failure 500 {object} object{error=string}
 // @Router /api/v1/auth/forgot-password [post]
 func (ah *AuthHandler) ForgotPassword(c *gin.Context) {
@@ -230,23 +620,29 @@ func (ah *AuthHandler) ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	resetToken := uuid.NewString()
-	user.ResetToken = resetToken
-	if err := ah.Repo.UpdateUser(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate reset token"})
-		return
-	}
-
-	resetLink := os.Getenv("API_URL") + "/api/v1/auth/reset-password?token=" + resetToken
-	if err := utils.SendResetPasswordEmail(user.Email, resetLink); err != nil {
+	if err := ah.sendPasswordResetEmail(user); err != nil {
 		log.Printf("Error sending reset email to %s: %v", user.Email, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send reset email"})
 		return
 	}
 
+	ah.recordEvent(c, models.SecurityEventPasswordResetRequested, user, "")
+
 	c.JSON(http.StatusOK, gin.H{"message": "If an account exists, a reset link has been sent"})
 }
 
+// sendPasswordResetEmail issues a new reset token for the user and emails the
+// link to reset the password with it.
+func (ah *AuthHandler) sendPasswordResetEmail(user *models.User) error {
+	resetToken, err := ah.issueUserToken(user.ID, models.TokenPurposePasswordReset, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	resetLink := os.Getenv("API_URL") + "/api/v1/auth/reset-password?token=" + resetToken
+	return utils.SendResetPasswordEmail(user.Email, user.Locale, resetLink)
+}
+
 type ResetPasswordRequest struct {
 	Token       string `json:"token" binding:"required"`
 	NewPassword string `json:"new_password" binding:"required,min=8,password" example:"Password*1"`
@@ -273,7 +669,7 @@ func (ah *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	user, err := ah.Repo.FindByResetToken(req.Token)
+	userToken, err := ah.UserTokens.ConsumeUserToken(utils.HashToken(req.Token), models.TokenPurposePasswordReset)
 	if err != nil {
 		if errors.Is(err, repositories.ErrTokenNotFound) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
@@ -283,6 +679,16 @@ func (ah *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
+	user, err := ah.Repo.FindByID(userToken.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal error"})
+		}
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error encrypting password"})
@@ -290,11 +696,148 @@ func (ah *AuthHandler) ResetPassword(c *gin.Context) {
 	}
 
 	user.Password = string(hashedPassword)
-	user.ResetToken = ""
-	if err := ah.Repo.UpdateUser(user); err != nil {
+	user.PasswordResetRequired = false
+	if err := ah.Repo.UpdateUser(user, "password", "password_reset_required"); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update password"})
 		return
 	}
 
+	if err := ah.UserTokens.InvalidateUserTokens(user.ID, models.TokenPurposePasswordReset); err != nil {
+		log.Printf("Could not invalidate reset tokens for user %d: %v", user.ID, err)
+	}
+
+	if err := ah.revokeAllTokens(user.ID); err != nil {
+		log.Printf("Could not revoke tokens for user %d after password reset: %v", user.ID, err)
+	}
+
+	ah.recordEvent(c, models.SecurityEventPasswordResetCompleted, user, "")
+
 	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Password successfully updated for %s", user.Email)})
 }
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the access token used for this request and end its session, which also revokes the session's refresh tokens
+// @Tags authentication
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/logout [post]
+func (ah *AuthHandler) Logout(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	sessionID, _ := c.Get("sessionID")
+	tokenID, _ := c.Get("tokenID")
+	expiresAt, _ := c.Get("tokenExpiresAt")
+
+	if err := ah.Revocations.RevokeToken(tokenID.(string), uint(userID.(int)), expiresAt.(time.Time)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke token"})
+		return
+	}
+
+	if err := ah.endSession(sessionID.(string), uint(userID.(int))); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not end session"})
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventTokenRevoked, &models.User{ID: uint(userID.(int)), Email: c.GetString("userEmail")}, "logout")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll godoc
+// @Summary Log out everywhere
+// @Description End every session of the authenticated user and revoke all of their access, refresh and personal access tokens
+// @Tags authentication
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/logout-all [post]
+func (ah *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := ah.revokeAllTokens(uint(userID.(int))); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke tokens"})
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventTokenRevoked, &models.User{ID: uint(userID.(int)), Email: c.GetString("userEmail")}, "logout_all")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions"})
+}
+
+// issueUserToken creates a single-use token for the user and invalidates the
+// ones previously issued for the same purpose, so only the latest emailed
+// link works.
+func (ah *AuthHandler) issueUserToken(userID uint, purpose models.TokenPurpose, ttl time.Duration) (string, error) {
+	return ah.issueUserTokenWithData(userID, purpose, ttl, "")
+}
+
+func (ah *AuthHandler) issueUserTokenWithData(userID uint, purpose models.TokenPurpose, ttl time.Duration, data string) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := ah.UserTokens.InvalidateUserTokens(userID, purpose); err != nil {
+		return "", err
+	}
+
+	record := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		Data:      data,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := ah.UserTokens.CreateUserToken(&record); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// sendVerificationEmail issues a new verification token and emails the link
+// to the user.
+func (ah *AuthHandler) sendVerificationEmail(user *models.User) error {
+	token, err := ah.issueUserToken(user.ID, models.TokenPurposeEmailVerification, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	verificationLink := os.Getenv("FRONT_URL") + "/auth/verify-email?token=" + token
+	return utils.SendVerificationEmail(user.Email, user.Locale, verificationLink)
+}
+
+// claimUnverifiedAccount verifies an account whose address was just proven
+// by a login through the email or an identity provider. Whoever registered
+// the address never proved owning it, so the password they chose and any
+// session they have must not keep working for the real owner's account.
+// method names how the address was proven in the audit log.
+func (ah *AuthHandler) claimUnverifiedAccount(c *gin.Context, user *models.User, method string) error {
+	user.IsVerified = true
+	user.Password = ""
+	if err := ah.Repo.UpdateUser(user, "is_verified", "password"); err != nil {
+		return err
+	}
+	ah.recordEvent(c, models.SecurityEventEmailVerified, user, method)
+	return ah.revokeAllTokens(user.ID)
+}
+
+// revokeAllTokens ends every session of the user and revokes all of their
+// access, refresh and personal access tokens.
+func (ah *AuthHandler) revokeAllTokens(userID uint) error {
+	if err := ah.Revocations.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	if _, err := ah.Sessions.DeleteUserSessions(userID, ""); err != nil {
+		return err
+	}
+	if err := ah.RefreshRepo.RevokeAllByUserID(userID); err != nil {
+		return err
+	}
+	return ah.PersonalAccessTokens.RevokeAllByUserID(userID)
+}
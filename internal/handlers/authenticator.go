@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator checks the credentials of a password login and returns the
+// user they belong to. When the password is wrong it returns
+// ErrInvalidCredentials, along with the user if the account is known, so
+// the owner can be warned about a lockout.
+type Authenticator interface {
+	Authenticate(email, password string) (*models.User, error)
+}
+
+// LoadAuthenticator builds the authenticators listed in AUTH_BACKENDS, a comma
+// separated list of "password" and "ldap" tried in order. It defaults to
+// password only.
+func LoadAuthenticator(users repositories.AuthRepository) (Authenticator, error) {
+	backends := os.Getenv("AUTH_BACKENDS")
+	if backends == "" {
+		backends = "password"
+	}
+
+	var chain chainAuthenticator
+	for _, backend := range strings.Split(backends, ",") {
+		switch strings.TrimSpace(backend) {
+		case "password":
+			chain = append(chain, NewPasswordAuthenticator(users))
+		case "ldap":
+			ldap, err := NewLDAPAuthenticator(LoadLDAPConfig(), users)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, ldap)
+		default:
+			return nil, fmt.Errorf("unknown auth backend %q", backend)
+		}
+	}
+
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// PasswordAuthenticator checks the bcrypt password hash stored for the user.
+type PasswordAuthenticator struct {
+	users repositories.AuthRepository
+}
+
+func NewPasswordAuthenticator(users repositories.AuthRepository) *PasswordAuthenticator {
+	return &PasswordAuthenticator{users: users}
+}
+
+func (pa *PasswordAuthenticator) Authenticate(email, password string) (*models.User, error) {
+	user, err := pa.users.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return user, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// chainAuthenticator tries each authenticator in turn until one accepts the
+// credentials.
+type chainAuthenticator []Authenticator
+
+func (ca chainAuthenticator) Authenticate(email, password string) (*models.User, error) {
+	var knownUser *models.User
+	for _, authenticator := range ca {
+		user, err := authenticator.Authenticate(email, password)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrInvalidCredentials) {
+			return nil, err
+		}
+		if knownUser == nil {
+			knownUser = user
+		}
+	}
+	return knownUser, ErrInvalidCredentials
+}
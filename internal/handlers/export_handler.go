@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	users     repositories.AuthRepository
+	tasks     repositories.TaskRepository
+	reminders repositories.ReminderRepository
+	sessions  repositories.SessionRepository
+	tokens    repositories.PersonalAccessTokenRepository
+	events    repositories.SecurityEventRepository
+}
+
+func NewExportHandler(users repositories.AuthRepository, tasks repositories.TaskRepository, reminders repositories.ReminderRepository,
+	sessions repositories.SessionRepository, tokens repositories.PersonalAccessTokenRepository,
+	events repositories.SecurityEventRepository) *ExportHandler {
+	return &ExportHandler{users: users, tasks: tasks, reminders: reminders, sessions: sessions, tokens: tokens, events: events}
+}
+
+type profileExport struct {
+	*models.UserDTO
+	CreatedAt  time.Time `json:"created_at"`
+	IsVerified bool      `json:"is_verified"`
+}
+
+// taskExport is a task with its reminders and subtasks. Subtasks have none
+// of their own.
+type taskExport struct {
+	*models.TaskDTO
+	Reminders []models.Reminder `json:"reminders"`
+	Subtasks  []*taskExport     `json:"subtasks"`
+}
+
+// ExportData godoc
+// @Summary Export account data
+// @Description Download a ZIP archive with the authenticated user's profile, tasks with their subtasks and reminders, categories, sessions, personal access tokens and security events as JSON files
+// @Tags account
+// @Produce application/zip
+// @Security ApiKeyAuth
+// @Success 200 {file} file
+// @Failure 401 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/me/export [post]
+func (eh *ExportHandler) ExportData(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	sessionID := c.GetString("sessionID")
+
+	user, err := eh.users.FindByID(uint(userID.(int)))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	tasks, err := eh.tasks.GetTasksByUserID(userID.(int), repositories.TaskFilter{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching tasks"})
+		return
+	}
+
+	reminders, err := eh.reminders.GetRemindersByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reminders"})
+		return
+	}
+
+	sessions, err := eh.sessions.GetSessionsByUserID(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching sessions"})
+		return
+	}
+
+	tokens, err := eh.tokens.GetTokensByUserID(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching tokens"})
+		return
+	}
+
+	events, err := eh.events.GetEventsByUserID(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching security events"})
+		return
+	}
+	if events == nil {
+		events = []models.SecurityEvent{}
+	}
+
+	taskReminders := make(map[uint][]models.Reminder)
+	for _, reminder := range reminders {
+		taskReminders[reminder.TaskID] = append(taskReminders[reminder.TaskID], reminder)
+	}
+
+	exports := make(map[uint]*taskExport, len(tasks))
+	categoryDTOs := []*models.CategoryDTO{}
+	seenCategories := make(map[uint]bool)
+	for _, task := range tasks {
+		export := &taskExport{TaskDTO: task.ToDTO(), Reminders: taskReminders[task.ID], Subtasks: []*taskExport{}}
+		if export.Reminders == nil {
+			export.Reminders = []models.Reminder{}
+		}
+		exports[task.ID] = export
+
+		if !seenCategories[task.CategoryID] {
+			seenCategories[task.CategoryID] = true
+			categoryDTOs = append(categoryDTOs, task.Category.ToDTO())
+		}
+	}
+
+	taskExports := []*taskExport{}
+	for _, task := range tasks {
+		if task.ParentID == 0 {
+			taskExports = append(taskExports, exports[task.ID])
+		} else if parent, ok := exports[task.ParentID]; ok {
+			parent.Subtasks = append(parent.Subtasks, exports[task.ID])
+		}
+	}
+
+	sessionDTOs := []*models.SessionDTO{}
+	for _, session := range sessions {
+		sessionDTOs = append(sessionDTOs, session.ToDTO(sessionID))
+	}
+
+	tokenDTOs := []*models.PersonalAccessTokenDTO{}
+	for _, token := range tokens {
+		tokenDTOs = append(tokenDTOs, token.ToDTO())
+	}
+
+	archive, err := buildExportArchive([]exportFile{
+		{"profile.json", profileExport{UserDTO: user.ToDTO(), CreatedAt: user.CreatedAt, IsVerified: user.IsVerified}},
+		{"tasks.json", taskExports},
+		{"categories.json", categoryDTOs},
+		{"sessions.json", sessionDTOs},
+		{"personal_access_tokens.json", tokenDTOs},
+		{"security_events.json", events},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build export"})
+		return
+	}
+
+	filename := fmt.Sprintf("sylcot-export-%s.zip", time.Now().In(user.Location()).Format("2006-01-02"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+type exportFile struct {
+	name    string
+	content interface{}
+}
+
+func buildExportArchive(files []exportFile) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
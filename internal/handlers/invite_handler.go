@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetInviteCodes godoc
+// @Summary List invite codes
+// @Description List the invite codes that have not been revoked, including used up and expired ones. Code values are never returned again after creation. Admin only.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.InviteCode
+// @Failure 403 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/invites [get]
+func (ah *AdminHandler) GetInviteCodes(c *gin.Context) {
+	invites, err := ah.auth.Invites.GetInviteCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching invite codes"})
+		return
+	}
+
+	if invites == nil {
+		invites = []models.InviteCode{}
+	}
+	c.JSON(http.StatusOK, invites)
+}
+
+// CreateInviteCode godoc
+// @Summary Mint an invite code
+// @Description Create a code that lets people register while registration is invite-only. It can be used max_uses times (default 1) until it expires. The code value is only returned in this response. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param invite body models.InviteCodeRequest true "Invite code settings"
+// @Security ApiKeyAuth
+// @Success 201 {object} object{code=string,details=models.InviteCode}
+// @Failure 400 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/invites [post]
+func (ah *AdminHandler) CreateInviteCode(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req models.InviteCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite code data"})
+		return
+	}
+
+	code, err := utils.GenerateInviteCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate invite code"})
+		return
+	}
+
+	invite := models.InviteCode{
+		CreatedByID: uint(userID.(int)),
+		Note:        req.Note,
+		CodeHash:    utils.HashToken(code),
+		Prefix:      models.DisplayPrefix(code),
+		MaxUses:     req.MaxUses,
+	}
+	if invite.MaxUses == 0 {
+		invite.MaxUses = 1
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := ah.auth.Invites.CreateInviteCode(&invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create invite code"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"code": code, "details": invite})
+}
+
+// DeleteInviteCode godoc
+// @Summary Revoke an invite code
+// @Description Revoke an invite code so it can no longer be used. Accounts already registered with it are not affected. Admin only.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Invite code ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/invites/{id} [delete]
+func (ah *AdminHandler) DeleteInviteCode(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := ah.auth.Invites.RevokeInviteCode(id); err != nil {
+		if errors.Is(err, repositories.ErrInviteCodeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite code not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking invite code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Invite code %d revoked successfully", id)})
+}
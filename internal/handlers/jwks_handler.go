@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// jwksMaxAge is how long verifiers may cache the key set. It has to stay
+// below the delay before a new key starts signing.
+const jwksMaxAge = "300"
+
+type JWKSHandler struct {
+	keys *utils.JWTKeySet
+}
+
+func NewJWKSHandler(keys *utils.JWTKeySet) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// OpenIDConfiguration is the discovery document describing how to validate
+// Sylcot access tokens. Sylcot is not an OAuth authorization server, so it
+// lists no endpoints to obtain tokens from.
+type OpenIDConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+// GetJWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys access tokens are signed with, so other services can verify them. Tokens name their key in the kid header.
+// @Tags authentication
+// @Produce json
+// @Success 200 {object} utils.JWKS
+// @Router /.well-known/jwks.json [get]
+func (jh *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age="+jwksMaxAge)
+	c.JSON(http.StatusOK, jh.keys.JWKS())
+}
+
+// GetOpenIDConfiguration godoc
+// @Summary OpenID discovery document
+// @Description Issuer, key set and signing algorithms of access tokens, for API gateways validating them. Access tokens carry the configured audience in aud; tokens issued for other purposes, like finishing a two-factor login, have a different audience and must be rejected.
+// @Tags authentication
+// @Produce json
+// @Success 200 {object} OpenIDConfiguration
+// @Router /.well-known/openid-configuration [get]
+func (jh *JWKSHandler) GetOpenIDConfiguration(c *gin.Context) {
+	issuer := utils.GetJWTIssuer()
+
+	c.Header("Cache-Control", "public, max-age="+jwksMaxAge)
+	c.JSON(http.StatusOK, OpenIDConfiguration{
+		Issuer:                           issuer,
+		JWKSURI:                          issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:           []string{"token"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: jh.keys.Algorithms(),
+		ClaimsSupported:                  []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "email", "role", "sid"},
+	})
+}
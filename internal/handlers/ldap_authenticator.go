@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const ldapTimeout = 10 * time.Second
+
+// LDAPConfig describes how to find and authenticate users in a directory.
+type LDAPConfig struct {
+	URL          string
+	StartTLS     bool
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds the entry of a user; %s is replaced with the escaped
+	// email.
+	UserFilter     string
+	NameAttribute  string
+	GroupAttribute string
+	// RoleGroups maps group DNs to the role their members get. Members of
+	// several groups get the most privileged role.
+	RoleGroups map[string]models.Role
+}
+
+// LoadLDAPConfig reads the directory settings from LDAP_URL, LDAP_START_TLS,
+// LDAP_BIND_DN, LDAP_BIND_PASSWORD, LDAP_BASE_DN, LDAP_USER_FILTER,
+// LDAP_NAME_ATTRIBUTE, LDAP_GROUP_ATTRIBUTE and LDAP_ROLE_GROUPS. The latter
+// is a semicolon separated list of role=group DN pairs, like
+// "admin=cn=admins,ou=groups,dc=example,dc=com".
+func LoadLDAPConfig() LDAPConfig {
+	config := LDAPConfig{
+		URL:            os.Getenv("LDAP_URL"),
+		StartTLS:       os.Getenv("LDAP_START_TLS") == "true",
+		BindDN:         os.Getenv("LDAP_BIND_DN"),
+		BindPassword:   os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:         os.Getenv("LDAP_BASE_DN"),
+		UserFilter:     os.Getenv("LDAP_USER_FILTER"),
+		NameAttribute:  os.Getenv("LDAP_NAME_ATTRIBUTE"),
+		GroupAttribute: os.Getenv("LDAP_GROUP_ATTRIBUTE"),
+		RoleGroups:     make(map[string]models.Role),
+	}
+	if config.UserFilter == "" {
+		config.UserFilter = "(&(objectClass=person)(mail=%s))"
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = "cn"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	for _, mapping := range strings.Split(os.Getenv("LDAP_ROLE_GROUPS"), ";") {
+		role, group, ok := strings.Cut(strings.TrimSpace(mapping), "=")
+		if ok {
+			config.RoleGroups[strings.ToLower(group)] = models.Role(role)
+		}
+	}
+	return config
+}
+
+// LDAPAuthenticator checks credentials by binding to a directory as the
+// user. Users are created on their first login and, when role groups are
+// configured, get their role from their groups on every login.
+type LDAPAuthenticator struct {
+	config LDAPConfig
+	users  repositories.AuthRepository
+}
+
+func NewLDAPAuthenticator(config LDAPConfig, users repositories.AuthRepository) (*LDAPAuthenticator, error) {
+	if config.URL == "" || config.BaseDN == "" {
+		return nil, errors.New("LDAP_URL and LDAP_BASE_DN are required")
+	}
+	for _, role := range config.RoleGroups {
+		if !models.IsValidRole(role) {
+			return nil, fmt.Errorf("invalid role %q in LDAP_ROLE_GROUPS", role)
+		}
+	}
+	return &LDAPAuthenticator{config: config, users: users}, nil
+}
+
+func (la *LDAPAuthenticator) Authenticate(email, password string) (*models.User, error) {
+	// An empty password would be an unauthenticated bind, which directories
+	// accept without checking anything.
+	if password == "" {
+		return la.knownUser(email)
+	}
+
+	conn, err := ldap.DialURL(la.config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetTimeout(ldapTimeout)
+
+	if la.config.StartTLS {
+		host := strings.TrimPrefix(strings.TrimPrefix(la.config.URL, "ldap://"), "ldaps://")
+		if err := conn.StartTLS(&tls.Config{ServerName: strings.Split(host, ":")[0]}); err != nil {
+			return nil, err
+		}
+	}
+
+	if la.config.BindDN != "" {
+		if err := conn.Bind(la.config.BindDN, la.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("service bind: %w", err)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		la.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		fmt.Sprintf(la.config.UserFilter, ldap.EscapeFilter(email)),
+		[]string{la.config.NameAttribute, la.config.GroupAttribute},
+		nil,
+	))
+	// Some directories answer a search without matches with No Such Object.
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) &&
+		!ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return nil, err
+	}
+	if result == nil || len(result.Entries) != 1 {
+		return la.knownUser(email)
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return la.knownUser(email)
+		}
+		return nil, err
+	}
+
+	return la.provision(email, entry)
+}
+
+// provision returns the local user for a directory entry, creating it on the
+// first login and syncing the role from the groups.
+func (la *LDAPAuthenticator) provision(email string, entry *ldap.Entry) (*models.User, error) {
+	role, mapped := la.role(entry.GetAttributeValues(la.config.GroupAttribute))
+
+	user, err := la.users.FindByEmail(email)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		user = &models.User{
+			Name:       provisionedUserName(entry.GetAttributeValue(la.config.NameAttribute), email),
+			Email:      email,
+			IsVerified: true,
+			Role:       role,
+		}
+		return user, la.users.CreateUser(user)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if (mapped && user.Role != role) || !user.IsVerified {
+		if mapped {
+			user.Role = role
+		}
+		if !user.IsVerified {
+			// As with OpenID Connect, a password chosen by whoever registered
+			// the unverified address must not keep working.
+			user.IsVerified = true
+			user.Password = ""
+		}
+		if err := la.users.UpdateUser(user, "role", "is_verified", "password"); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// role returns the most privileged role mapped to one of the groups, and
+// whether role groups are configured at all.
+func (la *LDAPAuthenticator) role(groups []string) (models.Role, bool) {
+	if len(la.config.RoleGroups) == 0 {
+		return models.RoleUser, false
+	}
+
+	role := models.RoleUser
+	for _, group := range groups {
+		switch la.config.RoleGroups[strings.ToLower(group)] {
+		case models.RoleAdmin:
+			role = models.RoleAdmin
+		case models.RoleSupport:
+			if role != models.RoleAdmin {
+				role = models.RoleSupport
+			}
+		}
+	}
+	return role, true
+}
+
+// knownUser rejects the credentials, returning the local user with that email
+// if there is one.
+func (la *LDAPAuthenticator) knownUser(email string) (*models.User, error) {
+	user, err := la.users.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	return user, ErrInvalidCredentials
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jimlambrt/gldap"
+	"gorm.io/gorm"
+)
+
+const (
+	testLDAPBaseDN       = "dc=example,dc=com"
+	testLDAPServiceDN    = "cn=service,dc=example,dc=com"
+	testLDAPServiceBind  = "service-secret"
+	testLDAPAdminsGroup  = "cn=admins,ou=groups,dc=example,dc=com"
+	testLDAPSupportGroup = "cn=support,ou=groups,dc=example,dc=com"
+)
+
+type testLDAPEntry struct {
+	dn       string
+	password string
+	name     string
+	groups   []string
+}
+
+// testDirectory is an in-process LDAP server holding people keyed by email.
+// It answers service and user binds and searches by mail.
+type testDirectory struct {
+	addr string
+
+	mu     sync.Mutex
+	people map[string]*testLDAPEntry
+}
+
+func startTestDirectory(t *testing.T) *testDirectory {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("find a free port: %v", err)
+	}
+	d := &testDirectory{addr: listener.Addr().String(), people: make(map[string]*testLDAPEntry)}
+	listener.Close()
+
+	server, err := gldap.NewServer()
+	if err != nil {
+		t.Fatalf("create LDAP server: %v", err)
+	}
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatalf("create LDAP mux: %v", err)
+	}
+	mux.Bind(d.bind)
+	mux.Search(d.search)
+	server.Router(mux)
+
+	go server.Run(d.addr)
+	t.Cleanup(func() { server.Stop() })
+
+	for deadline := time.Now().Add(5 * time.Second); !server.Ready(); {
+		if time.Now().After(deadline) {
+			t.Fatal("LDAP server did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return d
+}
+
+func (d *testDirectory) add(email string, entry *testLDAPEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.people[email] = entry
+}
+
+func (d *testDirectory) setGroups(email string, groups ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.people[email].groups = groups
+}
+
+func (d *testDirectory) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer w.Write(resp)
+
+	m, err := r.GetSimpleBindMessage()
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if m.UserName == testLDAPServiceDN && string(m.Password) == testLDAPServiceBind {
+		resp.SetResultCode(gldap.ResultSuccess)
+		return
+	}
+	for _, person := range d.people {
+		if m.UserName == person.dn && string(m.Password) == person.password {
+			resp.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+	}
+}
+
+func (d *testDirectory) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+	defer w.Write(resp)
+
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		resp.SetResultCode(gldap.ResultOperationsError)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for email, person := range d.people {
+		if strings.Contains(m.Filter, "(mail="+email+")") {
+			w.Write(r.NewSearchResponseEntry(person.dn, gldap.WithAttributes(map[string][]string{
+				"cn":       {person.name},
+				"mail":     {email},
+				"memberOf": person.groups,
+			})))
+		}
+	}
+}
+
+func newLDAPTestRouter(t *testing.T, directory *testDirectory) (*gin.Engine, *gorm.DB, *AuthHandler) {
+	t.Helper()
+
+	db := newTestDB(t)
+	auth := newTestAuthHandler(t, db)
+	ldapAuth, err := NewLDAPAuthenticator(LDAPConfig{
+		URL:            "ldap://" + directory.addr,
+		BindDN:         testLDAPServiceDN,
+		BindPassword:   testLDAPServiceBind,
+		BaseDN:         testLDAPBaseDN,
+		UserFilter:     "(&(objectClass=person)(mail=%s))",
+		NameAttribute:  "cn",
+		GroupAttribute: "memberOf",
+		RoleGroups: map[string]models.Role{
+			testLDAPAdminsGroup:  models.RoleAdmin,
+			testLDAPSupportGroup: models.RoleSupport,
+		},
+	}, auth.Repo)
+	if err != nil {
+		t.Fatalf("create LDAP authenticator: %v", err)
+	}
+	auth.Authenticator = ldapAuth
+
+	router := gin.New()
+	router.POST("/auth/login", auth.Login)
+	return router, db, auth
+}
+
+func ldapLogin(router *gin.Engine, email, password string) (*httptest.ResponseRecorder, map[string]any) {
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return serve(router, req)
+}
+
+func TestLDAPLoginProvisionsUser(t *testing.T) {
+	directory := startTestDirectory(t)
+	directory.add("ann@example.com", &testLDAPEntry{
+		dn:       "uid=ann,ou=people,dc=example,dc=com",
+		password: "directory-secret",
+		name:     "Ann Example",
+		groups:   []string{testLDAPSupportGroup},
+	})
+	router, db, _ := newLDAPTestRouter(t, directory)
+
+	rec, body := ldapLogin(router, "ann@example.com", "directory-secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body)
+	}
+	if body["token"] == nil {
+		t.Errorf("login did not return a token: %v", body)
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", "ann@example.com").First(&user).Error; err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if user.Name != "Ann Example" || user.Role != models.RoleSupport || !user.IsVerified || user.Password != "" {
+		t.Errorf("provisioned %q with role %q, verified %v, password %q", user.Name, user.Role, user.IsVerified, user.Password)
+	}
+}
+
+func TestLDAPLoginRejectsInvalidCredentials(t *testing.T) {
+	directory := startTestDirectory(t)
+	directory.add("ann@example.com", &testLDAPEntry{
+		dn:       "uid=ann,ou=people,dc=example,dc=com",
+		password: "directory-secret",
+		name:     "Ann Example",
+	})
+	router, db, _ := newLDAPTestRouter(t, directory)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{"wrong password", "ann@example.com", "guess"},
+		{"empty password", "ann@example.com", ""},
+		{"unknown user", "bob@example.com", "directory-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec, _ := ldapLogin(router, tt.email, tt.password); rec.Code != http.StatusUnauthorized {
+				t.Errorf("status %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d users were provisioned, want none", count)
+	}
+}
+
+func TestLDAPLoginSyncsRoleAndEndsSessions(t *testing.T) {
+	directory := startTestDirectory(t)
+	directory.add("ann@example.com", &testLDAPEntry{
+		dn:       "uid=ann,ou=people,dc=example,dc=com",
+		password: "directory-secret",
+		name:     "Ann Example",
+		groups:   []string{testLDAPAdminsGroup},
+	})
+	router, db, auth := newLDAPTestRouter(t, directory)
+
+	if rec, _ := ldapLogin(router, "ann@example.com", "directory-secret"); rec.Code != http.StatusOK {
+		t.Fatalf("first login: status %d, body %s", rec.Code, rec.Body)
+	}
+	var user models.User
+	db.Where("email = ?", "ann@example.com").First(&user)
+	adminSessions, _ := auth.Sessions.GetSessionsByUserID(user.ID)
+	if user.Role != models.RoleAdmin || len(adminSessions) != 1 {
+		t.Fatalf("after first login role %q with %d sessions, want admin with one", user.Role, len(adminSessions))
+	}
+
+	directory.setGroups("ann@example.com")
+	if rec, _ := ldapLogin(router, "ann@example.com", "directory-secret"); rec.Code != http.StatusOK {
+		t.Fatalf("second login: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	db.First(&user, user.ID)
+	if user.Role != models.RoleUser {
+		t.Errorf("role %q, want %q", user.Role, models.RoleUser)
+	}
+	if user.TokensRevokedAt == nil {
+		t.Error("access tokens issued with the admin role were not revoked")
+	}
+	sessions, _ := auth.Sessions.GetSessionsByUserID(user.ID)
+	if len(sessions) != 1 || sessions[0].ID == adminSessions[0].ID {
+		t.Errorf("the admin session was not ended: %v", sessions)
+	}
+	if events := eventsOf(t, db, user.ID); !slices.Contains(events, models.SecurityEventRoleChanged) {
+		t.Errorf("no %s event among %v", models.SecurityEventRoleChanged, events)
+	}
+}
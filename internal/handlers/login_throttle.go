@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// loginBackoffBase is the delay imposed after the first failure past the
+	// free attempts; it doubles with every further failure.
+	loginBackoffBase = time.Second
+	// loginFailuresResetAfter forgets failures once nobody has failed to log
+	// in for that long.
+	loginFailuresResetAfter = time.Hour
+)
+
+// lockoutPolicy decides how long a subject has to wait after a number of
+// consecutive failures.
+type lockoutPolicy struct {
+	freeAttempts int
+	maxFailures  int
+	lockout      time.Duration
+}
+
+func (p lockoutPolicy) delay(failures int) time.Duration {
+	if failures < p.freeAttempts {
+		return 0
+	}
+	if failures >= p.maxFailures {
+		return p.lockout
+	}
+	delay := loginBackoffBase
+	for i := p.freeAttempts; i < failures && delay < p.lockout; i++ {
+		delay *= 2
+	}
+	if delay > p.lockout {
+		return p.lockout
+	}
+	return delay
+}
+
+// LoginThrottle slows down password guessing. Failures are counted both per
+// account and per client IP, with exponential backoff after a few failures
+// and a temporary lockout once the maximum is reached.
+type LoginThrottle struct {
+	store   repositories.LoginAttemptStore
+	account lockoutPolicy
+	ip      lockoutPolicy
+}
+
+// NewLoginThrottle reads its limits from LOGIN_MAX_FAILURES,
+// LOGIN_MAX_FAILURES_PER_IP and LOGIN_LOCKOUT_MINUTES.
+func NewLoginThrottle(store repositories.LoginAttemptStore) *LoginThrottle {
+	lockout := time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+	return &LoginThrottle{
+		store: store,
+		account: lockoutPolicy{
+			freeAttempts: 3,
+			maxFailures:  envInt("LOGIN_MAX_FAILURES", 10),
+			lockout:      lockout,
+		},
+		ip: lockoutPolicy{
+			freeAttempts: 10,
+			maxFailures:  envInt("LOGIN_MAX_FAILURES_PER_IP", 50),
+			lockout:      lockout,
+		},
+	}
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// retryAfter returns how long the account and the client IP still have to
+// wait before trying again, whichever is longer.
+func (lt *LoginThrottle) retryAfter(email, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, check := range []struct {
+		subject string
+		policy  lockoutPolicy
+	}{
+		{repositories.AccountSubject(email), lt.account},
+		{repositories.IPSubject(ip), lt.ip},
+	} {
+		attempt, err := lt.store.GetLoginAttempt(check.subject)
+		if err != nil {
+			return 0, err
+		}
+		if attempt == nil {
+			continue
+		}
+		if remaining := time.Until(attempt.LastFailedAt.Add(check.policy.delay(attempt.Failures))); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait, nil
+}
+
+// recordFailure counts a failed attempt. lockedOut is true only for the
+// failure that locked the account, so the owner is notified once.
+func (lt *LoginThrottle) recordFailure(email, ip string) (lockedOut bool, err error) {
+	if _, err := lt.store.RecordLoginFailure(repositories.IPSubject(ip), loginFailuresResetAfter); err != nil {
+		return false, err
+	}
+	attempt, err := lt.store.RecordLoginFailure(repositories.AccountSubject(email), loginFailuresResetAfter)
+	if err != nil {
+		return false, err
+	}
+	return attempt.Failures == lt.account.maxFailures, nil
+}
+
+// recordSuccess clears the failures of the account. Failures of the client
+// IP are kept, so one valid account does not reset a password spraying run.
+func (lt *LoginThrottle) recordSuccess(email string) error {
+	return lt.store.ResetLoginAttempts(repositories.AccountSubject(email))
+}
+
+// rejectThrottledLogin responds with 429 and returns true while the account
+// or client IP is backing off or locked out. user is nil when the account was
+// not loaded yet.
+func (ah *AuthHandler) rejectThrottledLogin(c *gin.Context, email string, user *models.User) bool {
+	wait, err := ah.Throttle.retryAfter(email, c.ClientIP())
+	if err != nil {
+		log.Printf("Could not check login attempts for %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return true
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	ah.recordLoginFailure(c, email, user, loginFailureLockedOut)
+	respondThrottled(c, wait)
+	return true
+}
+
+func respondThrottled(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts. Please try again later"})
+}
+
+// failLogin records a failed attempt, with reason in the audit log, and
+// responds with the given error, or with 429 if the failure started a backoff.
+// user is nil when no account matched the email.
+func (ah *AuthHandler) failLogin(c *gin.Context, email string, user *models.User, reason string, status int, message string) {
+	ah.recordLoginFailure(c, email, user, reason)
+
+	lockedOut, err := ah.Throttle.recordFailure(email, c.ClientIP())
+	if err != nil {
+		log.Printf("Could not record failed login for %s: %v", email, err)
+	}
+
+	if lockedOut && user != nil {
+		until := utils.FormatEmailTime(time.Now().Add(ah.Throttle.account.lockout), user.Location())
+		if err := utils.SendAccountLockedEmail(user.Email, user.Locale, until); err != nil {
+			log.Printf("Could not send lockout email to %s: %v", user.Email, err)
+		}
+	}
+
+	wait, err := ah.Throttle.retryAfter(email, c.ClientIP())
+	if err != nil {
+		log.Printf("Could not check login attempts for %s: %v", email, err)
+	}
+	if wait > 0 {
+		respondThrottled(c, wait)
+		return
+	}
+	c.JSON(status, gin.H{"error": message})
+}
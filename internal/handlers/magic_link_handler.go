@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	magicLinkTokenTTL = 15 * time.Minute
+	// magicLinkInterval limits how often a link is emailed to one account.
+	magicLinkInterval = time.Minute
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@example.com"`
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token      string `json:"token" binding:"required"`
+	DeviceName string `json:"device_name" binding:"max=100" example:"Firefox on Linux"`
+}
+
+// RequestMagicLink godoc
+// @Summary Request a sign-in link
+// @Description Email a single-use link that logs the user in without a password. It expires after 15 minutes and requesting a new one invalidates the previous link. The response is the same whether or not the account exists.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param email body MagicLinkRequest true "Registered email address"
+// @Success 202 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/magic-link [post]
+func (ah *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+		return
+	}
+
+	user, err := ah.Repo.FindByEmail(req.Email)
+	if err != nil || user.IsSuspended() {
+		c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists, a sign-in link has been sent"})
+		return
+	}
+
+	lastIssuedAt, err := ah.UserTokens.LastIssuedAt(user.ID, models.TokenPurposeMagicLink)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if time.Since(lastIssuedAt) < magicLinkInterval {
+		c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists, a sign-in link has been sent"})
+		return
+	}
+
+	token, err := ah.issueUserToken(user.ID, models.TokenPurposeMagicLink, magicLinkTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate sign-in link"})
+		return
+	}
+
+	magicLink := os.Getenv("FRONT_URL") + "/auth/magic-link?token=" + token
+	if err := utils.SendMagicLinkEmail(user.Email, user.Locale, magicLink); err != nil {
+		log.Printf("Could not send sign-in link to %s: %v", user.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send sign-in link"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists, a sign-in link has been sent"})
+}
+
+// ConsumeMagicLink godoc
+// @Summary Log in with a sign-in link
+// @Description Exchange the token of an emailed sign-in link for the access and refresh tokens. Using the link proves ownership of the address, so an unverified account becomes verified; its password is removed and its other sessions are logged out, since whoever registered it never proved owning the address. Accounts with two-factor authentication still get an mfa_token to complete.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param token body ConsumeMagicLinkRequest true "Sign-in link token"
+// @Success 200 {object} object{token=string,refresh_token=string,expires_in=int,user=models.UserDTO,account_restored=bool,mfa_required=bool,mfa_token=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/magic-link/consume [post]
+func (ah *AuthHandler) ConsumeMagicLink(c *gin.Context) {
+	var req ConsumeMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	userToken, err := ah.UserTokens.ConsumeUserToken(utils.HashToken(req.Token), models.TokenPurposeMagicLink)
+	if err != nil {
+		if errors.Is(err, repositories.ErrTokenNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	user, err := ah.Repo.FindByID(userToken.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	if !ah.checkAccountStatus(c, user) {
+		return
+	}
+
+	if !user.IsVerified {
+		if err := ah.claimUnverifiedAccount(c, user, "magic_link"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating the user"})
+			return
+		}
+		if err := ah.UserTokens.InvalidateUserTokens(user.ID, models.TokenPurposeEmailVerification); err != nil {
+			log.Printf("Could not invalidate verification tokens for user %d: %v", user.ID, err)
+		}
+	}
+
+	ah.finishLogin(c, user, req.DeviceName, "magic_link")
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	totpIssuer        = "Sylcot"
+	recoveryCodeCount = 10
+)
+
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"abcde-fghij"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type RecoveryCodesRequest struct {
+	Password string `json:"password" example:"Password*1"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+type TOTPDisableRequest struct {
+	Password     string `json:"password" example:"Password*1"`
+	Code         string `json:"code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"abcde-fghij"`
+}
+
+// VerifyMFA godoc
+// @Summary Complete a two-factor login
+// @Description Exchange the mfa_token returned by login plus a TOTP code, or one of the recovery codes, for the access and refresh tokens
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param verify body MFAVerifyRequest true "Pending login token and second factor"
+// @Success 200 {object} object{token=string,refresh_token=string,expires_in=int,user=models.UserDTO,account_restored=bool}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/mfa/verify [post]
+func (ah *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	userID, deviceName, err := ah.Keys.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	user, err := ah.Repo.FindByID(uint(userID))
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	if !ah.checkAccountStatus(c, user) {
+		return
+	}
+
+	if !ah.confirmSecondFactor(c, user, req.Code, req.RecoveryCode) {
+		return
+	}
+
+	if err := ah.Throttle.recordSuccess(user.Email); err != nil {
+		log.Printf("Could not reset login attempts for %s: %v", user.Email, err)
+	}
+
+	method := "password+totp"
+	if req.Code == "" {
+		method = "password+recovery_code"
+	}
+	ah.completeLogin(c, user, deviceName, method)
+}
+
+// SetupTOTP godoc
+// @Summary Start TOTP enrollment
+// @Description Generate a new TOTP secret for the authenticated user. It only takes effect once confirmed with a valid code.
+// @Tags mfa
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} object{secret=string,otpauth_uri=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/mfa/totp/setup [post]
+func (ah *AuthHandler) SetupTOTP(c *gin.Context) {
+	user, ok := ah.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate secret"})
+		return
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := ah.Repo.UpdateUser(user, "totp_secret", "totp_last_step"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating the user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP godoc
+// @Summary Confirm TOTP enrollment
+// @Description Enable two-factor authentication with a first code from the authenticator app. Returns one-time recovery codes, which are shown only once.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param code body TOTPCodeRequest true "Code from the authenticator app"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{recovery_codes=[]string}
+// @Failure 400 {object} object{error=string}
+// @Failure 409 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/mfa/totp/confirm [post]
+func (ah *AuthHandler) ConfirmTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	user, ok := ah.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor setup has not been started"})
+		return
+	}
+
+	step, valid := utils.ValidateTOTP(user.TOTPSecret, req.Code, user.TOTPLastStep)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	recoveryCodes, err := ah.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate recovery codes"})
+		return
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	if err := ah.Repo.UpdateUser(user, "totp_enabled", "totp_last_step"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating the user"})
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventMFAEnabled, user, "totp")
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// DisableTOTP godoc
+// @Summary Disable TOTP
+// @Description Turn off two-factor authentication. Requires a current code or a recovery code, and the password or, for accounts without one, a recent login. Wrong codes and passwords count as failed logins.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param disable body TOTPDisableRequest true "Password and second factor"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/mfa/totp/disable [post]
+func (ah *AuthHandler) DisableTOTP(c *gin.Context) {
+	var req TOTPDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	user, ok := ah.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	// The second factor comes first: checking the password resets the
+	// failed attempts, which must not give code guesses a fresh start.
+	if !ah.confirmSecondFactor(c, user, req.Code, req.RecoveryCode) {
+		return
+	}
+	if !ah.checkCurrentPassword(c, user, req.Password) {
+		return
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	if err := ah.Repo.UpdateUser(user, "totp_enabled", "totp_secret", "totp_last_step"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating the user"})
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventMFADisabled, user, "totp")
+
+	if err := ah.Recovery.DeleteRecoveryCodes(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes with a new set. Requires a current TOTP code, and the password or, for accounts without one, a recent login. Wrong codes and passwords count as failed logins.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param code body RecoveryCodesRequest true "Code from the authenticator app and password"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{recovery_codes=[]string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 429 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/auth/mfa/recovery-codes [post]
+func (ah *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req RecoveryCodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	user, ok := ah.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if !ah.confirmSecondFactor(c, user, req.Code, "") {
+		return
+	}
+	if !ah.checkCurrentPassword(c, user, req.Password) {
+		return
+	}
+
+	recoveryCodes, err := ah.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate recovery codes"})
+		return
+	}
+
+	ah.recordEvent(c, models.SecurityEventRecoveryCodesRegenerated, user, "")
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// confirmSecondFactor checks a TOTP code or a recovery code of the user,
+// writing the error response itself. Wrong codes count as failed logins, so
+// they cannot be guessed without running into the lockout.
+func (ah *AuthHandler) confirmSecondFactor(c *gin.Context, user *models.User, code, recoveryCode string) bool {
+	if ah.rejectThrottledLogin(c, user.Email, user) {
+		return false
+	}
+
+	valid, err := ah.checkSecondFactor(user, code, recoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return false
+	}
+	if !valid {
+		ah.failLogin(c, user.Email, user, loginFailureInvalidCode, http.StatusUnauthorized, "Invalid code")
+		return false
+	}
+	return true
+}
+
+// checkSecondFactor validates either a TOTP code or a recovery code. A
+// matching TOTP code is remembered so it cannot be used twice, even by
+// concurrent requests, and a matching recovery code is consumed.
+func (ah *AuthHandler) checkSecondFactor(user *models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, valid := utils.ValidateTOTP(user.TOTPSecret, code, user.TOTPLastStep)
+		if !valid {
+			return false, nil
+		}
+		advanced, err := ah.Repo.AdvanceTOTPStep(user.ID, step)
+		if err != nil || !advanced {
+			return false, err
+		}
+		user.TOTPLastStep = step
+		return true, nil
+	}
+
+	hash := utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))
+	return ah.Recovery.ConsumeRecoveryCode(user.ID, hash)
+}
+
+func (ah *AuthHandler) replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	if err := ah.Recovery.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// currentUser loads the authenticated user, writing an error response and
+// returning false when that is not possible.
+func (ah *AuthHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := c.Get("userID")
+
+	user, err := ah.Repo.FindByID(uint(userID.(int)))
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+)
+
+// currentTOTP returns the code an authenticator app shows for the secret now.
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestCheckSecondFactorRejectsConcurrentReplay(t *testing.T) {
+	db := newTestDB(t)
+	auth := newTestAuthHandler(t, db)
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	user := &models.User{Name: "Ann", Email: "ann@example.com", IsVerified: true, TOTPEnabled: true, TOTPSecret: secret}
+	if err := auth.Repo.CreateUser(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// Both requests load the user before either of them uses the code.
+	first, _ := auth.Repo.FindByID(user.ID)
+	second, _ := auth.Repo.FindByID(user.ID)
+	code := currentTOTP(t, secret)
+
+	if ok, err := auth.checkSecondFactor(first, code, ""); err != nil || !ok {
+		t.Fatalf("first use: ok %v, err %v", ok, err)
+	}
+	if ok, err := auth.checkSecondFactor(second, code, ""); err != nil || ok {
+		t.Errorf("replayed code: ok %v, err %v, want rejected", ok, err)
+	}
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcLoginStateTTL = 10 * time.Minute
+	oidcTimeout       = 10 * time.Second
+	// oidcStateCookie holds the state of the login started in the browser,
+	// so a callback URL from someone else's login cannot be completed in it.
+	oidcStateCookie = "oidc_state"
+)
+
+// OIDCProvider is an OpenID Connect provider users can log in with.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// LoadOIDCProviders reads the providers named in OIDC_PROVIDERS, a comma
+// separated list. Each one is configured with OIDC_<NAME>_ISSUER,
+// OIDC_<NAME>_CLIENT_ID and OIDC_<NAME>_CLIENT_SECRET, and optionally
+// OIDC_<NAME>_SCOPES and OIDC_<NAME>_REDIRECT_URL.
+func LoadOIDCProviders() (map[string]*OIDCProvider, error) {
+	providers := make(map[string]*OIDCProvider)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := &OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+		}
+		if provider.RedirectURL == "" {
+			provider.RedirectURL = utils.GetJWTIssuer() + "/api/v1/auth/oidc/" + name + "/callback"
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// discover fetches the provider's discovery document the first time it is
+// needed, so a provider that is down does not keep the API from starting.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		provider, err := oidc.NewProvider(ctx, p.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		p.provider = provider
+	}
+	return p.provider, nil
+}
+
+func (p *OIDCProvider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.Scopes,
+	}
+}
+
+type OIDCHandler struct {
+	providers map[string]*OIDCProvider
+	repo      repositories.OIDCRepository
+	auth      *AuthHandler
+}
+
+func NewOIDCHandler(providers map[string]*OIDCProvider, repo repositories.OIDCRepository, auth *AuthHandler) *OIDCHandler {
+	return &OIDCHandler{providers: providers, repo: repo, auth: auth}
+}
+
+// oidcClaims are the claims of the ID token used to find or create the user.
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// StartLogin godoc
+// @Summary Start an OpenID Connect login
+// @Description Redirect the browser to the provider's login page. Once the user signs in there, the provider redirects back to the callback, which logs them in. A cookie ties the login to the browser that started it.
+// @Tags authentication
+// @Param provider path string true "Provider name, as configured in OIDC_PROVIDERS"
+// @Param device_name query string false "Name of the device, shown in the session list"
+// @Success 302
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Failure 502 {object} object{error=string}
+// @Router /api/v1/auth/oidc/{provider}/start [get]
+func (oh *OIDCHandler) StartLogin(c *gin.Context) {
+	provider, ok := oh.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown provider"})
+		return
+	}
+
+	deviceName := c.Query("device_name")
+	if utf8.RuneCountInString(deviceName) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), oidcTimeout)
+	defer cancel()
+	discovered, err := provider.discover(ctx)
+	if err != nil {
+		log.Printf("Could not discover OIDC provider %s: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Provider unavailable"})
+		return
+	}
+
+	state, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start login"})
+		return
+	}
+	nonce, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start login"})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = oh.repo.CreateLoginState(&models.OIDCLoginState{
+		StateHash:    utils.HashToken(state),
+		Provider:     provider.Name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		DeviceName:   deviceName,
+		ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start login"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcLoginStateTTL.Seconds()), oidcCookiePath(c),
+		"", strings.HasPrefix(provider.RedirectURL, "https://"), true)
+
+	authURL := provider.oauth2Config(discovered).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback godoc
+// @Summary Finish an OpenID Connect login
+// @Description The provider redirects here after the user signed in, in the browser that started the login. The account is found by the provider identity or, for a first login, by the verified email, which is then linked; otherwise a new, verified account is created if registration is open to the email domain. Responds like login.
+// @Tags authentication
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State sent to the provider"
+// @Success 200 {object} object{token=string,refresh_token=string,expires_in=int,user=models.UserDTO,account_restored=bool,mfa_required=bool,mfa_token=string}
+// @Failure 400 {object} object{error=string}
+// @Failure 401 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Failure 502 {object} object{error=string}
+// @Router /api/v1/auth/oidc/{provider}/callback [get]
+func (oh *OIDCHandler) Callback(c *gin.Context) {
+	provider, ok := oh.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown provider"})
+		return
+	}
+
+	if c.Query("error") != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login was cancelled or denied by the provider"})
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	// Without this, anyone could start a login and have a victim open its
+	// callback URL, logging the victim into the attacker's account.
+	browserState, err := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath(c), "", strings.HasPrefix(provider.RedirectURL, "https://"), true)
+	if err != nil || subtle.ConstantTimeCompare([]byte(browserState), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+
+	loginState, err := oh.repo.ConsumeLoginState(utils.HashToken(state), provider.Name)
+	if err != nil {
+		if errors.Is(err, repositories.ErrLoginStateInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), oidcTimeout)
+	defer cancel()
+	discovered, err := provider.discover(ctx)
+	if err != nil {
+		log.Printf("Could not discover OIDC provider %s: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Provider unavailable"})
+		return
+	}
+
+	token, err := provider.oauth2Config(discovered).Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		log.Printf("Could not exchange OIDC code with %s: %v", provider.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Could not complete login with the provider"})
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := discovered.Verifier(&oidc.Config{ClientID: provider.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil || idToken.Nonce != loginState.Nonce {
+		log.Printf("Invalid ID token from OIDC provider %s: %v", provider.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Could not complete login with the provider"})
+		return
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Could not complete login with the provider"})
+		return
+	}
+
+	user, ok := oh.resolveUser(c, provider.Name, idToken.Subject, claims)
+	if !ok {
+		return
+	}
+
+	if !oh.auth.checkAccountStatus(c, user) {
+		return
+	}
+
+	oh.auth.finishLogin(c, user, loginState.DeviceName, "oidc:"+provider.Name)
+}
+
+// oidcCookiePath scopes the state cookie to the login routes of the provider.
+func oidcCookiePath(c *gin.Context) string {
+	return path.Dir(c.Request.URL.Path)
+}
+
+// resolveUser finds the user a provider identity belongs to, linking it to
+// the account with the same verified email, or to a new account, on the first
+// login. It writes the error response itself.
+func (oh *OIDCHandler) resolveUser(c *gin.Context, provider, subject string, claims oidcClaims) (*models.User, bool) {
+	identity, err := oh.repo.FindIdentity(provider, subject)
+	if err == nil {
+		user, err := oh.auth.Repo.FindByID(identity.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return nil, false
+		}
+		return user, true
+	}
+	if !errors.Is(err, repositories.ErrIdentityNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The provider did not confirm your email address"})
+		return nil, false
+	}
+
+	user, err := oh.auth.Repo.FindByEmail(claims.Email)
+	switch {
+	case errors.Is(err, repositories.ErrUserNotFound):
+		// Invite codes cannot be carried through the provider, so unless
+		// registration is open only existing accounts can sign in.
+		switch oh.auth.Registration.Mode {
+		case RegistrationClosed:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Registration is closed"})
+			return nil, false
+		case RegistrationInvite:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Register with your invite code before signing in with this provider"})
+			return nil, false
+		}
+		if !oh.auth.Registration.AllowsEmail(claims.Email) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Registration is not allowed for this email domain"})
+			return nil, false
+		}
+		user = &models.User{
+			Name:       provisionedUserName(claims.Name, claims.Email),
+			Email:      claims.Email,
+			IsVerified: true,
+			Locale:     requestLocale(c),
+		}
+		if err := oh.auth.Repo.CreateUser(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not register the user"})
+			return nil, false
+		}
+		oh.auth.recordEvent(c, models.SecurityEventRegistered, user, "oidc:"+provider)
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	case !user.IsVerified:
+		if err := oh.auth.claimUnverifiedAccount(c, user, "oidc:"+provider); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return nil, false
+		}
+	}
+
+	err = oh.repo.CreateIdentity(&models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not link account"})
+		return nil, false
+	}
+	return user, true
+}
+
+// provisionedUserName returns the name of a user created from an external
+// identity, falling back to the local part of the email, trimmed to fit the
+// validation rules.
+func provisionedUserName(name, email string) string {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) < 2 {
+		name = strings.SplitN(email, "@", 2)[0]
+	}
+	if runes := []rune(name); len(runes) > 50 {
+		name = string(runes[:50])
+	}
+	return name
+}
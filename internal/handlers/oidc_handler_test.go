@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// mockIssuer is an OpenID Connect provider that signs in whoever it is
+// configured with. Its token endpoint enforces PKCE like a real provider.
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+
+	subject  string
+	email    string
+	verified bool
+	// nonce, when set, replaces the nonce of the login in ID tokens.
+	nonce string
+}
+
+type mockAuthorization struct {
+	clientID      string
+	redirectURI   string
+	challenge     string
+	challengeType string
+	nonce         string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	m := &mockIssuer{
+		key:      key,
+		codes:    make(map[string]mockAuthorization),
+		subject:  "subject-1",
+		email:    "ann@example.com",
+		verified: true,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.token)
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// authorize plays the user signing in at the authorization URL and returns
+// the query the provider redirects back to the callback with.
+func (m *mockIssuer) authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	query := parsed.Query()
+
+	code := "code-" + query.Get("state")
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{
+		clientID:      query.Get("client_id"),
+		redirectURI:   query.Get("redirect_uri"),
+		challenge:     query.Get("code_challenge"),
+		challengeType: query.Get("code_challenge_method"),
+		nonce:         query.Get("nonce"),
+	}
+	m.mu.Unlock()
+
+	return url.Values{"code": {code}, "state": {query.Get("state")}}
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.Form.Get("code")]
+	delete(m.codes, r.Form.Get("code"))
+	m.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || auth.challengeType != "S256" ||
+		auth.challenge != base64.RawURLEncoding.EncodeToString(verifier[:]) ||
+		auth.redirectURI != r.Form.Get("redirect_uri") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := auth.nonce
+	if m.nonce != "" {
+		nonce = m.nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            m.subject,
+		"aud":            auth.clientID,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          m.email,
+		"email_verified": m.verified,
+		"name":           "Ann",
+	})
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func newOIDCTestRouter(t *testing.T, issuer *mockIssuer) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+
+	db := newTestDB(t)
+	providers := make(map[string]*OIDCProvider)
+	for _, name := range []string{"mock", "other"} {
+		providers[name] = &OIDCProvider{
+			Name:         name,
+			Issuer:       issuer.URL,
+			ClientID:     "sylcot",
+			ClientSecret: "secret",
+			RedirectURL:  "http://api.test/api/v1/auth/oidc/" + name + "/callback",
+			Scopes:       []string{"openid", "email", "profile"},
+		}
+	}
+	handler := NewOIDCHandler(providers, repositories.NewOIDCRepository(db), newTestAuthHandler(t, db))
+
+	router := gin.New()
+	router.GET("/auth/oidc/:provider/start", handler.StartLogin)
+	router.GET("/auth/oidc/:provider/callback", handler.Callback)
+	return router, db
+}
+
+// startOIDCLogin starts a login with the provider and returns the URL the
+// browser is sent to, along with the state cookie it is given.
+func startOIDCLogin(t *testing.T, router *gin.Engine, provider string) (string, *http.Cookie) {
+	t.Helper()
+
+	rec, _ := serve(router, httptest.NewRequest(http.MethodGet, "/auth/oidc/"+provider+"/start", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("start: status %d, body %s", rec.Code, rec.Body)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == oidcStateCookie {
+			if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+				t.Errorf("state cookie HttpOnly %v, SameSite %v", cookie.HttpOnly, cookie.SameSite)
+			}
+			return rec.Header().Get("Location"), cookie
+		}
+	}
+	t.Fatal("start did not set the state cookie")
+	return "", nil
+}
+
+// oidcCallback plays the provider redirecting the browser back, sending the
+// state cookie when there is one.
+func oidcCallback(router *gin.Engine, provider string, query url.Values, cookie *http.Cookie) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/"+provider+"/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return serve(router, req)
+}
+
+func TestOIDCLoginCreatesVerifiedUser(t *testing.T) {
+	issuer := newMockIssuer(t)
+	router, db := newOIDCTestRouter(t, issuer)
+
+	authURL, cookie := startOIDCLogin(t, router, "mock")
+	sent, _ := url.Parse(authURL)
+	if got := sent.Query().Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", got)
+	}
+	if sent.Query().Get("nonce") == "" {
+		t.Error("authorization URL has no nonce")
+	}
+
+	query := issuer.authorize(t, authURL)
+	rec, body := oidcCallback(router, "mock", query, cookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback: status %d, body %s", rec.Code, rec.Body)
+	}
+	if body["token"] == nil || body["refresh_token"] == nil {
+		t.Errorf("callback did not return tokens: %v", body)
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", issuer.email).First(&user).Error; err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+	if !user.IsVerified || user.Password != "" {
+		t.Errorf("user verified %v with password %q, want verified without password", user.IsVerified, user.Password)
+	}
+
+	var identity models.UserIdentity
+	if err := db.Where("provider = ? AND subject = ?", "mock", issuer.subject).First(&identity).Error; err != nil {
+		t.Fatalf("identity was not linked: %v", err)
+	}
+	if identity.UserID != user.ID {
+		t.Errorf("identity linked to user %d, want %d", identity.UserID, user.ID)
+	}
+
+	// The state is used up, so the redirect cannot be replayed.
+	if rec, _ := oidcCallback(router, "mock", query, cookie); rec.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCCallbackRejectsInvalidState(t *testing.T) {
+	issuer := newMockIssuer(t)
+	router, _ := newOIDCTestRouter(t, issuer)
+
+	authURL, cookie := startOIDCLogin(t, router, "mock")
+	query := issuer.authorize(t, authURL)
+	forged := &http.Cookie{Name: oidcStateCookie, Value: "forged"}
+	_, otherCookie := startOIDCLogin(t, router, "mock")
+
+	tests := []struct {
+		name     string
+		provider string
+		query    url.Values
+		cookie   *http.Cookie
+	}{
+		{"unknown state", "mock", url.Values{"code": query["code"], "state": {"forged"}}, forged},
+		{"state of another provider", "other", query, cookie},
+		{"missing state", "mock", url.Values{"code": query["code"]}, cookie},
+		{"missing cookie", "mock", query, nil},
+		{"cookie of another login", "mock", query, otherCookie},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec, _ := oidcCallback(router, tt.provider, tt.query, tt.cookie); rec.Code != http.StatusBadRequest {
+				t.Errorf("status %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRejectsWrongCodeVerifier(t *testing.T) {
+	issuer := newMockIssuer(t)
+	router, db := newOIDCTestRouter(t, issuer)
+
+	authURL, cookie := startOIDCLogin(t, router, "mock")
+	query := issuer.authorize(t, authURL)
+	if err := db.Model(&models.OIDCLoginState{}).Where("1 = 1").Update("code_verifier", "not-the-verifier").Error; err != nil {
+		t.Fatalf("update login state: %v", err)
+	}
+
+	if rec, _ := oidcCallback(router, "mock", query, cookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCCallbackRejectsWrongNonce(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.nonce = "replayed-nonce"
+	router, db := newOIDCTestRouter(t, issuer)
+
+	authURL, cookie := startOIDCLogin(t, router, "mock")
+	query := issuer.authorize(t, authURL)
+	if rec, _ := oidcCallback(router, "mock", query, cookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d users were created, want none", count)
+	}
+}
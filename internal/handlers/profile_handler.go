@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileHandler struct {
+	users      repositories.AuthRepository
+	categories repositories.CategoryRepository
+}
+
+func NewProfileHandler(users repositories.AuthRepository, categories repositories.CategoryRepository) *ProfileHandler {
+	return &ProfileHandler{users: users, categories: categories}
+}
+
+// GetProfile godoc
+// @Summary Get the current user
+// @Description Get the profile and preferences of the authenticated user
+// @Tags account
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.UserDTO
+// @Failure 401 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/me [get]
+func (ph *ProfileHandler) GetProfile(c *gin.Context) {
+	user, ok := ph.currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToDTO())
+}
+
+// UpdateProfile godoc
+// @Summary Update the current user
+// @Description Update the profile and preferences of the authenticated user. Only the fields sent are changed; send an empty avatar_url to remove the avatar and a default_category_id of 0 to clear the default category. The email is changed through /api/v1/me/email.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param profile body models.UserProfileRequest true "Fields to change"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.UserDTO
+// @Failure 400 {object} object{error=string,details=object}
+// @Failure 401 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/me [patch]
+func (ph *ProfileHandler) UpdateProfile(c *gin.Context) {
+	var req models.UserProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile data"})
+		return
+	}
+
+	validationErrors := req.Validate(utils.SupportedLocales)
+	if req.DefaultCategoryID != nil && *req.DefaultCategoryID != 0 {
+		if _, err := ph.categories.GetCategoryByID(*req.DefaultCategoryID); err != nil {
+			if !errors.Is(err, repositories.ErrCategoryNotFound) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching category"})
+				return
+			}
+			validationErrors["default_category_id"] = append(validationErrors["default_category_id"], "Category not found")
+		}
+	}
+	if len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": validationErrors,
+		})
+		return
+	}
+
+	user, ok := ph.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		user.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.AvatarURL != nil {
+		user.AvatarURL = *req.AvatarURL
+	}
+	if req.TimeZone != nil {
+		user.TimeZone = *req.TimeZone
+	}
+	if req.Locale != nil {
+		user.Locale = *req.Locale
+	}
+	if req.WeekStart != nil {
+		user.WeekStart = *req.WeekStart
+	}
+	if req.DefaultPriority != nil {
+		user.DefaultPriority = *req.DefaultPriority
+	}
+	if req.DefaultCategoryID != nil {
+		if *req.DefaultCategoryID == 0 {
+			user.DefaultCategoryID = nil
+		} else {
+			user.DefaultCategoryID = req.DefaultCategoryID
+		}
+	}
+
+	if err := ph.users.UpdateUser(user, "name", "avatar_url", "time_zone", "locale", "week_start", "default_priority", "default_category_id"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToDTO())
+}
+
+func (ph *ProfileHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := c.Get("userID")
+
+	user, err := ph.users.FindByID(uint(userID.(int)))
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Registration modes accepted in REGISTRATION_MODE.
+const (
+	RegistrationOpen   = "open"
+	RegistrationClosed = "closed"
+	RegistrationInvite = "invite"
+)
+
+// RegistrationPolicy decides who may create an account. Domains match the
+// domain itself and its subdomains; denied domains win over allowed ones, and
+// an empty allow list allows every domain that is not denied.
+type RegistrationPolicy struct {
+	Mode           string
+	AllowedDomains []string
+	DeniedDomains  []string
+}
+
+// LoadRegistrationPolicy reads REGISTRATION_MODE (open, closed or invite,
+// default open) and the comma separated REGISTRATION_ALLOWED_DOMAINS and
+// REGISTRATION_DENIED_DOMAINS.
+func LoadRegistrationPolicy() (RegistrationPolicy, error) {
+	policy := RegistrationPolicy{
+		Mode:           strings.ToLower(strings.TrimSpace(os.Getenv("REGISTRATION_MODE"))),
+		AllowedDomains: domainList(os.Getenv("REGISTRATION_ALLOWED_DOMAINS")),
+		DeniedDomains:  domainList(os.Getenv("REGISTRATION_DENIED_DOMAINS")),
+	}
+	switch policy.Mode {
+	case "":
+		policy.Mode = RegistrationOpen
+	case RegistrationOpen, RegistrationClosed, RegistrationInvite:
+	default:
+		return policy, fmt.Errorf("unknown registration mode %q", policy.Mode)
+	}
+	return policy, nil
+}
+
+// AllowsEmail reports whether the domain of the email may register.
+func (p RegistrationPolicy) AllowsEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	matches := func(listed string) bool {
+		return domain == listed || strings.HasSuffix(domain, "."+listed)
+	}
+	if slices.ContainsFunc(p.DeniedDomains, matches) {
+		return false
+	}
+	return len(p.AllowedDomains) == 0 || slices.ContainsFunc(p.AllowedDomains, matches)
+}
+
+func domainList(value string) []string {
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
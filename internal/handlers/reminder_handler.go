@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxRemindersPerTask = 10
+	maxReminderOffset   = 60 * 24 * 365
+)
+
+type ReminderHandler struct {
+	reminders repositories.ReminderRepository
+	tasks     repositories.TaskRepository
+}
+
+func NewReminderHandler(reminders repositories.ReminderRepository, tasks repositories.TaskRepository) *ReminderHandler {
+	return &ReminderHandler{reminders: reminders, tasks: tasks}
+}
+
+// GetReminders godoc
+// @Summary List the reminders of a task
+// @Description List the reminders of a task, soonest first, including sent and dismissed ones
+// @Tags reminders
+// @Produce json
+// @Param id path int true "Task ID"
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Reminder
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tasks/{id}/reminders [get]
+func (rh *ReminderHandler) GetReminders(c *gin.Context) {
+	task, ok := rh.findTask(c)
+	if !ok {
+		return
+	}
+
+	reminders, err := rh.reminders.GetRemindersByTask(task.ID, task.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reminders"})
+		return
+	}
+
+	if reminders == nil {
+		reminders = []models.Reminder{}
+	}
+	c.JSON(http.StatusOK, reminders)
+}
+
+// CreateReminder godoc
+// @Summary Add a reminder to a task
+// @Description Remind the user of the task by email at remind_at, or offset_minutes before the task is due. Tasks without a due time are taken as due at 09:00 in the user's time zone. Reminders relative to the due date follow it when it changes, also when a recurring task moves to its next occurrence.
+// @Tags reminders
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param reminder body models.ReminderRequest true "Reminder time"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.Reminder
+// @Failure 400 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tasks/{id}/reminders [post]
+func (rh *ReminderHandler) CreateReminder(c *gin.Context) {
+	task, ok := rh.findTask(c)
+	if !ok {
+		return
+	}
+
+	var req models.ReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder data"})
+		return
+	}
+
+	if (req.RemindAt == nil) == (req.OffsetMinutes == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set either remind_at or offset_minutes"})
+		return
+	}
+	if req.OffsetMinutes != nil && (*req.OffsetMinutes < 0 || *req.OffsetMinutes > maxReminderOffset) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Offset must be between 0 and %d minutes", maxReminderOffset)})
+		return
+	}
+
+	existing, err := rh.reminders.GetRemindersByTask(task.ID, task.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reminders"})
+		return
+	}
+	if len(existing) >= maxRemindersPerTask {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A task can have at most %d reminders", maxRemindersPerTask)})
+		return
+	}
+
+	reminder := models.Reminder{
+		TaskID:        task.ID,
+		UserID:        task.UserID,
+		RemindAt:      req.RemindAt,
+		OffsetMinutes: req.OffsetMinutes,
+	}
+	if !reminder.Schedule(task, task.User.Location()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The task has no due date"})
+		return
+	}
+	if reminder.FireAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The reminder time has already passed"})
+		return
+	}
+
+	if err := rh.reminders.CreateReminder(&reminder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create reminder"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// DeleteReminder godoc
+// @Summary Remove a reminder
+// @Description Remove a reminder from a task
+// @Tags reminders
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param reminderId path int true "Reminder ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tasks/{id}/reminders/{reminderId} [delete]
+func (rh *ReminderHandler) DeleteReminder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	taskID, _ := strconv.Atoi(c.Param("id"))
+	id, _ := strconv.Atoi(c.Param("reminderId"))
+
+	if err := rh.reminders.DeleteReminder(uint(id), uint(taskID), uint(userID.(int))); err != nil {
+		if errors.Is(err, repositories.ErrReminderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting reminder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Reminder %d deleted successfully", id)})
+}
+
+// SnoozeReminder godoc
+// @Summary Snooze a reminder
+// @Description Send the reminder again the given number of minutes from now (at most a week), whether it was already sent, dismissed or still pending
+// @Tags reminders
+// @Accept json
+// @Produce json
+// @Param id path int true "Reminder ID"
+// @Param snooze body models.SnoozeRequest true "Snooze duration"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Reminder
+// @Failure 400 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/reminders/{id}/snooze [post]
+func (rh *ReminderHandler) SnoozeReminder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req models.SnoozeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Snooze between 1 minute and 1 week"})
+		return
+	}
+
+	until := time.Now().Add(time.Duration(req.Minutes) * time.Minute).UTC()
+	reminder, err := rh.reminders.SnoozeReminder(uint(id), uint(userID.(int)), until)
+	respondWithReminder(c, reminder, err)
+}
+
+// DismissReminder godoc
+// @Summary Dismiss a reminder
+// @Description Mark a sent reminder as seen, or cancel a pending one
+// @Tags reminders
+// @Produce json
+// @Param id path int true "Reminder ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.Reminder
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/reminders/{id}/dismiss [post]
+func (rh *ReminderHandler) DismissReminder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	reminder, err := rh.reminders.DismissReminder(uint(id), uint(userID.(int)))
+	respondWithReminder(c, reminder, err)
+}
+
+func (rh *ReminderHandler) findTask(c *gin.Context) (*models.Task, bool) {
+	userID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	task, err := rh.tasks.GetTaskByID(id, userID.(int))
+	if err != nil {
+		if errors.Is(err, repositories.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching task"})
+		return nil, false
+	}
+	return task, true
+}
+
+func respondWithReminder(c *gin.Context, reminder *models.Reminder, err error) {
+	if err != nil {
+		if errors.Is(err, repositories.ErrReminderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating reminder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reminder)
+}
@@ -0,0 +1,566 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSCIMPageSize = 100
+	maxSCIMPageSize     = 200
+)
+
+// SCIMHandler serves the SCIM 2.0 Users endpoint identity providers use to
+// provision accounts. Deactivating a user suspends it, which blocks logins
+// and revokes its tokens the same way an administrator suspension does.
+type SCIMHandler struct {
+	auth *AuthHandler
+}
+
+func NewSCIMHandler(auth *AuthHandler) *SCIMHandler {
+	return &SCIMHandler{auth: auth}
+}
+
+// SCIMPatchRequest is a SCIM PATCH request. Operations without a path carry
+// an object of attributes to replace.
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+type SCIMPatchOperation struct {
+	Op    string          `json:"op" example:"replace"`
+	Path  string          `json:"path" example:"active"`
+	Value json.RawMessage `json:"value" swaggertype:"object"`
+}
+
+// GetUsers godoc
+// @Summary List provisioned users
+// @Description List users for the identity provider. The filter supports eq comparisons of userName, emails.value, externalId and active, joined with and.
+// @Tags scim
+// @Produce json
+// @Param filter query string false "SCIM filter, e.g. userName eq \"user@example.com\""
+// @Param startIndex query int false "1-based index of the first result" default(1)
+// @Param count query int false "Page size (max 200)" default(100)
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SCIMListResponse
+// @Failure 400 {object} models.SCIMError
+// @Failure 401 {object} models.SCIMError
+// @Failure 500 {object} models.SCIMError
+// @Router /scim/v2/Users [get]
+func (sh *SCIMHandler) GetUsers(c *gin.Context) {
+	filter, matchesNothing, err := parseSCIMFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+
+	startIndex, _ := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(defaultSCIMPageSize)))
+	if err != nil || count > maxSCIMPageSize {
+		count = defaultSCIMPageSize
+	}
+	if count < 0 {
+		count = 0
+	}
+	filter.Offset = startIndex - 1
+	filter.Limit = count
+
+	var users []models.User
+	var total int64
+	if !matchesNothing {
+		users, total, err = sh.auth.Repo.ListUsers(filter)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", "Error fetching users")
+			return
+		}
+	}
+
+	resources := []*models.SCIMUser{}
+	for _, user := range users {
+		resources = append(resources, user.ToSCIM(scimUserLocation(&user)))
+	}
+
+	scimJSON(c, http.StatusOK, models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// GetUser godoc
+// @Summary Get a provisioned user
+// @Tags scim
+// @Produce json
+// @Param id path string true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SCIMUser
+// @Failure 401 {object} models.SCIMError
+// @Failure 404 {object} models.SCIMError
+// @Failure 500 {object} models.SCIMError
+// @Router /scim/v2/Users/{id} [get]
+func (sh *SCIMHandler) GetUser(c *gin.Context) {
+	user, ok := sh.findUser(c)
+	if !ok {
+		return
+	}
+
+	sh.respondWithUser(c, http.StatusOK, user)
+}
+
+// CreateUser godoc
+// @Summary Provision a user
+// @Description Create a verified account for the userName email address. Its name is taken from displayName, name.formatted or the given and family names. Passwords are not accepted; users sign in through single sign-on, a magic link or the password reset flow. Creating a user deleted within the grace period restores it. An unverified account registered with the address is taken over: its password is cleared and its sessions and tokens are revoked.
+// @Tags scim
+// @Accept json
+// @Produce json
+// @Param user body models.SCIMUser true "User"
+// @Security ApiKeyAuth
+// @Success 201 {object} models.SCIMUser
+// @Failure 400 {object} models.SCIMError
+// @Failure 401 {object} models.SCIMError
+// @Failure 409 {object} models.SCIMError
+// @Failure 500 {object} models.SCIMError
+// @Router /scim/v2/Users [post]
+func (sh *SCIMHandler) CreateUser(c *gin.Context) {
+	var req models.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+		return
+	}
+
+	email := strings.TrimSpace(req.UserName)
+	if !isEmailAddress(email) {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName must be an email address")
+		return
+	}
+
+	user, err := sh.auth.Repo.FindByEmail(email)
+	claimed := false
+	switch {
+	case errors.Is(err, repositories.ErrUserNotFound):
+		user = &models.User{Email: email}
+	case err != nil:
+		scimError(c, http.StatusInternalServerError, "", "Error fetching user")
+		return
+	case user.DeletedAt == nil && user.IsVerified:
+		scimError(c, http.StatusConflict, "uniqueness", "A user with that userName already exists")
+		return
+	case user.DeletedAt == nil:
+		// Whoever registered the address never proved owning it; the
+		// identity provider vouches for the real owner.
+		if err := sh.auth.claimUnverifiedAccount(c, user, "scim"); err != nil {
+			scimError(c, http.StatusInternalServerError, "", "Could not create user")
+			return
+		}
+		claimed = true
+	default:
+		user.DeletedAt = nil
+		user.SuspendedAt = nil
+	}
+
+	user.IsVerified = true
+	user.ExternalID = req.ExternalID
+	user.Name = provisionedUserName(scimDisplayName(&req), email)
+	applySCIMLocale(user, req.Locale, req.Timezone)
+	if req.Active != nil && !*req.Active {
+		now := time.Now()
+		user.SuspendedAt = &now
+	}
+
+	if user.ID == 0 {
+		err = sh.auth.Repo.CreateUser(user)
+	} else {
+		err = sh.auth.Repo.UpdateUser(user, "deleted_at", "suspended_at", "is_verified", "external_id", "name", "locale", "time_zone")
+	}
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserAlreadyExists) {
+			scimError(c, http.StatusConflict, "uniqueness", "A user with that userName already exists")
+		} else {
+			scimError(c, http.StatusInternalServerError, "", "Could not create user")
+		}
+		return
+	}
+
+	if !claimed {
+		sh.auth.recordEvent(c, models.SecurityEventRegistered, user, "scim")
+	}
+
+	c.Header("Location", scimUserLocation(user))
+	sh.respondWithUser(c, http.StatusCreated, user)
+}
+
+// PatchUser godoc
+// @Summary Update a provisioned user
+// @Description Apply add, replace and remove operations to userName, displayName, name, externalId, locale, timezone and active. Setting active to false suspends the user and revokes all of their sessions and tokens. Other attributes are ignored.
+// @Tags scim
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param patch body SCIMPatchRequest true "Patch operations"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.SCIMUser
+// @Failure 400 {object} models.SCIMError
+// @Failure 401 {object} models.SCIMError
+// @Failure 404 {object} models.SCIMError
+// @Failure 409 {object} models.SCIMError
+// @Failure 500 {object} models.SCIMError
+// @Router /scim/v2/Users/{id} [patch]
+func (sh *SCIMHandler) PatchUser(c *gin.Context) {
+	var req SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Operations) == 0 {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid patch request")
+		return
+	}
+
+	user, ok := sh.findUser(c)
+	if !ok {
+		return
+	}
+
+	wasActive := !user.IsSuspended()
+	patched := user.ToSCIM("")
+	for _, operation := range req.Operations {
+		if err := applySCIMOperation(patched, operation); err != nil {
+			scimError(c, http.StatusBadRequest, err.scimType, err.detail)
+			return
+		}
+	}
+
+	email := strings.TrimSpace(patched.UserName)
+	if !isEmailAddress(email) {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName must be an email address")
+		return
+	}
+	if !strings.EqualFold(email, user.Email) {
+		if _, err := sh.auth.Repo.FindByEmail(email); err == nil {
+			scimError(c, http.StatusConflict, "uniqueness", "A user with that userName already exists")
+			return
+		} else if !errors.Is(err, repositories.ErrUserNotFound) {
+			scimError(c, http.StatusInternalServerError, "", "Error fetching user")
+			return
+		}
+	}
+
+	user.Email = email
+	user.ExternalID = patched.ExternalID
+	user.Name = provisionedUserName(scimDisplayName(patched), email)
+	applySCIMLocale(user, patched.Locale, patched.Timezone)
+	active := patched.Active == nil || *patched.Active
+	if wasActive && !active {
+		now := time.Now()
+		user.SuspendedAt = &now
+	} else if active {
+		user.SuspendedAt = nil
+	}
+
+	if err := sh.auth.Repo.UpdateUser(user, "email", "external_id", "name", "locale", "time_zone", "suspended_at"); err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Could not update user")
+		return
+	}
+
+	if wasActive && !active {
+		if err := sh.auth.revokeAllTokens(user.ID); err != nil {
+			scimError(c, http.StatusInternalServerError, "", "Could not revoke tokens")
+			return
+		}
+		sh.auth.recordEvent(c, models.SecurityEventTokenRevoked, user, "scim_deactivation")
+	}
+
+	sh.respondWithUser(c, http.StatusOK, user)
+}
+
+// DeleteUser godoc
+// @Summary Delete a provisioned user
+// @Description Delete the account and revoke all of its sessions and tokens. Its data is purged once the account deletion grace period is over.
+// @Tags scim
+// @Param id path string true "User ID"
+// @Security ApiKeyAuth
+// @Success 204
+// @Failure 401 {object} models.SCIMError
+// @Failure 404 {object} models.SCIMError
+// @Failure 500 {object} models.SCIMError
+// @Router /scim/v2/Users/{id} [delete]
+func (sh *SCIMHandler) DeleteUser(c *gin.Context) {
+	user, ok := sh.findUser(c)
+	if !ok {
+		return
+	}
+
+	// The account is suspended as well, so logging in does not restore it
+	// the way it restores accounts deleted by their owner.
+	now := time.Now()
+	user.DeletedAt = &now
+	user.SuspendedAt = &now
+	if err := sh.auth.Repo.UpdateUser(user, "deleted_at", "suspended_at"); err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Could not delete user")
+		return
+	}
+
+	if err := sh.auth.revokeAllTokens(user.ID); err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Could not revoke tokens")
+		return
+	}
+
+	sh.auth.recordEvent(c, models.SecurityEventTokenRevoked, user, "scim_deletion")
+
+	c.Status(http.StatusNoContent)
+}
+
+// findUser loads the user named by the id path parameter, writing the error
+// response itself. Deleted users are not found.
+func (sh *SCIMHandler) findUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 1 {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+
+	user, err := sh.auth.Repo.FindByID(uint(id))
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			scimError(c, http.StatusNotFound, "", "User not found")
+		} else {
+			scimError(c, http.StatusInternalServerError, "", "Error fetching user")
+		}
+		return nil, false
+	}
+	if user.DeletedAt != nil {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+func (sh *SCIMHandler) respondWithUser(c *gin.Context, status int, user *models.User) {
+	scimJSON(c, status, user.ToSCIM(scimUserLocation(user)))
+}
+
+func scimUserLocation(user *models.User) string {
+	return strings.TrimSuffix(os.Getenv("API_URL"), "/") + "/scim/v2/Users/" + strconv.FormatUint(uint64(user.ID), 10)
+}
+
+// scimJSON writes a response with the SCIM media type.
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", "application/scim+json; charset=utf-8")
+	c.JSON(status, body)
+}
+
+func scimError(c *gin.Context, status int, scimType, detail string) {
+	scimJSON(c, status, models.SCIMError{
+		Schemas:  []string{models.SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+func isEmailAddress(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email && len(email) <= 255
+}
+
+// scimDisplayName returns the name of the user: displayName, name.formatted
+// or the given and family names.
+func scimDisplayName(user *models.SCIMUser) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	if user.Name == nil {
+		return ""
+	}
+	if user.Name.Formatted != "" {
+		return user.Name.Formatted
+	}
+	return strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName)
+}
+
+// applySCIMLocale sets the locale and time zone of the user when they are
+// ones we support. Identity providers send locales like en-US, of which only
+// the language is used.
+func applySCIMLocale(user *models.User, locale, timeZone string) {
+	language := strings.ToLower(strings.SplitN(strings.ReplaceAll(locale, "_", "-"), "-", 2)[0])
+	if slices.Contains(utils.SupportedLocales, language) {
+		user.Locale = language
+	}
+	if timeZone != "" && timeZone != "Local" {
+		if _, err := time.LoadLocation(timeZone); err == nil {
+			user.TimeZone = timeZone
+		}
+	}
+}
+
+type scimPatchError struct {
+	scimType string
+	detail   string
+}
+
+// applySCIMOperation applies a patch operation to the SCIM representation of
+// a user. Attributes we do not store are ignored, so identity providers can
+// send their usual mappings.
+func applySCIMOperation(user *models.SCIMUser, operation SCIMPatchOperation) *scimPatchError {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return &scimPatchError{"invalidSyntax", "Unsupported operation " + operation.Op}
+	}
+
+	if operation.Path == "" {
+		if op == "remove" {
+			return &scimPatchError{"noTarget", "remove requires a path"}
+		}
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+			return &scimPatchError{"invalidValue", "The value of an operation without a path must be an object"}
+		}
+		for path, value := range attributes {
+			if err := applySCIMOperation(user, SCIMPatchOperation{Op: op, Path: path, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path := strings.ToLower(strings.TrimPrefix(operation.Path, models.SCIMSchemaUser+":"))
+	if op == "remove" {
+		switch path {
+		case "username", "active":
+			return &scimPatchError{"mutability", operation.Path + " cannot be removed"}
+		case "externalid":
+			user.ExternalID = ""
+		case "locale":
+			user.Locale = ""
+		case "timezone":
+			user.Timezone = ""
+		}
+		return nil
+	}
+
+	var err error
+	switch path {
+	case "username":
+		err = json.Unmarshal(operation.Value, &user.UserName)
+	case "externalid":
+		err = json.Unmarshal(operation.Value, &user.ExternalID)
+	case "displayname":
+		err = json.Unmarshal(operation.Value, &user.DisplayName)
+	case "name":
+		var name models.SCIMName
+		if err = json.Unmarshal(operation.Value, &name); err == nil {
+			user.DisplayName = scimDisplayName(&models.SCIMUser{Name: &name})
+		}
+	case "name.formatted":
+		err = json.Unmarshal(operation.Value, &user.DisplayName)
+	case "locale":
+		err = json.Unmarshal(operation.Value, &user.Locale)
+	case "timezone":
+		err = json.Unmarshal(operation.Value, &user.Timezone)
+	case "active":
+		var active bool
+		active, err = parseSCIMBool(operation.Value)
+		user.Active = &active
+	}
+	if err != nil {
+		return &scimPatchError{"invalidValue", "Invalid value for " + operation.Path}
+	}
+	return nil
+}
+
+// parseSCIMBool accepts booleans and, as some identity providers send them,
+// the strings "true" and "false".
+func parseSCIMBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(s))
+}
+
+// scimFilterCondition matches one comparison of a filter, like
+// userName eq "user@example.com".
+var scimFilterCondition = regexp.MustCompile(`(?i)^\s*([a-z.:0-9]+)\s+eq\s+("(?:[^"\\]|\\.)*"|true|false)\s*`)
+
+var scimFilterAnd = regexp.MustCompile(`(?i)^and\s+`)
+
+// parseSCIMFilter turns a filter of eq comparisons joined with and into a
+// user filter. matchesNothing is set when the comparisons contradict each
+// other.
+func parseSCIMFilter(filter string) (repositories.ProvisionedUserFilter, bool, error) {
+	var result repositories.ProvisionedUserFilter
+	matchesNothing := false
+	set := func(field *string, value string) {
+		if *field != "" && !strings.EqualFold(*field, value) {
+			matchesNothing = true
+		}
+		*field = value
+	}
+
+	rest := strings.TrimSpace(filter)
+	for rest != "" {
+		match := scimFilterCondition.FindStringSubmatch(rest)
+		if match == nil {
+			return result, false, errors.New("Only eq comparisons of userName, emails.value, externalId and active joined with and are supported")
+		}
+		rest = rest[len(match[0]):]
+		if rest != "" {
+			and := scimFilterAnd.FindString(rest)
+			if and == "" {
+				return result, false, errors.New("Only eq comparisons joined with and are supported")
+			}
+			rest = rest[len(and):]
+			if rest == "" {
+				return result, false, errors.New("Filter ends with and")
+			}
+		}
+
+		attribute := strings.ToLower(strings.TrimPrefix(match[1], models.SCIMSchemaUser+":"))
+		value := match[2]
+		if attribute == "active" {
+			active, err := strconv.ParseBool(strings.ToLower(value))
+			if err != nil {
+				return result, false, errors.New("active must be compared to true or false")
+			}
+			if result.Active != nil && *result.Active != active {
+				matchesNothing = true
+			}
+			result.Active = &active
+			continue
+		}
+
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return result, false, errors.New("Invalid string in filter")
+		}
+		switch attribute {
+		case "username", "emails", "emails.value":
+			set(&result.Email, unquoted)
+		case "externalid":
+			set(&result.ExternalID, unquoted)
+		default:
+			return result, false, errors.New("Filtering by " + match[1] + " is not supported")
+		}
+	}
+
+	return result, matchesNothing, nil
+}
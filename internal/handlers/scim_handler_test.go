@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func scimCreateUser(router *gin.Engine, user models.SCIMUser) (*httptest.ResponseRecorder, map[string]any) {
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest(http.MethodPost, "/scim/v2/Users", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	return serve(router, req)
+}
+
+func TestSCIMCreateUserClaimsUnverifiedAccount(t *testing.T) {
+	db := newTestDB(t)
+	auth := newTestAuthHandler(t, db)
+	router := gin.New()
+	router.POST("/scim/v2/Users", NewSCIMHandler(auth).CreateUser)
+
+	squatter := &models.User{Name: "Mallory", Email: "ann@example.com", Password: "chosen-by-someone-else"}
+	if err := auth.Repo.CreateUser(squatter); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := auth.Sessions.CreateSession(&models.Session{ID: "squatter-session", UserID: squatter.ID}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	rec, _ := scimCreateUser(router, models.SCIMUser{UserName: "ann@example.com", ExternalID: "ext-1", DisplayName: "Ann"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	var user models.User
+	db.First(&user, squatter.ID)
+	if !user.IsVerified || user.Password != "" || user.ExternalID != "ext-1" {
+		t.Errorf("claimed account verified %v, password %q, externalId %q", user.IsVerified, user.Password, user.ExternalID)
+	}
+	if sessions, _ := auth.Sessions.GetSessionsByUserID(user.ID); len(sessions) != 0 {
+		t.Errorf("%d sessions of the unverified account survived", len(sessions))
+	}
+	if events := eventsOf(t, db, user.ID); !slices.Contains(events, models.SecurityEventEmailVerified) {
+		t.Errorf("no %s event among %v", models.SecurityEventEmailVerified, events)
+	}
+
+	// Once verified, the address belongs to the account.
+	if rec, _ := scimCreateUser(router, models.SCIMUser{UserName: "ann@example.com", ExternalID: "ext-2"}); rec.Code != http.StatusConflict {
+		t.Errorf("create over a verified account: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultEventsPageSize = 20
+	maxEventsPageSize     = 100
+)
+
+// Details of failed login events.
+const (
+	loginFailureInvalidCredentials   = "invalid_credentials"
+	loginFailureInvalidCode          = "invalid_mfa_code"
+	loginFailureLockedOut            = "locked_out"
+	loginFailureUnverified           = "unverified"
+	loginFailureSuspended            = "suspended"
+	loginFailurePasswordResetPending = "password_reset_required"
+)
+
+// recordEvent appends an event about the user to the audit log, taking the
+// client IP, user agent and acting user from the request. Failing to record
+// is logged but does not fail the request.
+func (ah *AuthHandler) recordEvent(c *gin.Context, eventType models.SecurityEventType, user *models.User, detail string) {
+	event := models.SecurityEvent{
+		Type:   eventType,
+		Detail: detail,
+		IP:     c.ClientIP(),
+	}
+	if user != nil {
+		if user.ID != 0 {
+			event.UserID = &user.ID
+		}
+		event.Email = user.Email
+	}
+	if actorID, ok := c.Get("userID"); ok {
+		id := uint(actorID.(int))
+		event.ActorID = &id
+	}
+	if len(event.Detail) > 100 {
+		event.Detail = event.Detail[:100]
+	}
+	event.UserAgent = c.Request.UserAgent()
+	if len(event.UserAgent) > 512 {
+		event.UserAgent = event.UserAgent[:512]
+	}
+
+	if err := ah.Events.CreateEvent(&event); err != nil {
+		log.Printf("Could not record %s event for %s: %v", eventType, event.Email, err)
+	}
+}
+
+// recordLoginFailure records a failed login for the email. user is nil when
+// the account was not loaded yet; it is looked up so the owner sees attempts
+// against their account. Attempts against emails without an account are not
+// recorded, since anyone could fill the log with made-up addresses; the
+// login throttle still counts them.
+func (ah *AuthHandler) recordLoginFailure(c *gin.Context, email string, user *models.User, detail string) {
+	if user == nil {
+		found, err := ah.Repo.FindByEmail(email)
+		if err != nil {
+			if !errors.Is(err, repositories.ErrUserNotFound) {
+				log.Printf("Could not look up %s for the audit log: %v", email, err)
+			}
+			return
+		}
+		user = found
+	}
+	ah.recordEvent(c, models.SecurityEventLoginFailed, user, detail)
+}
+
+// GetSecurityEvents godoc
+// @Summary List my security events
+// @Description List the security events of the authenticated user, newest first: registration, logins and failed attempts, password and email changes, two-factor authentication changes, token refreshes and revocations, personal access tokens, and role changes.
+// @Tags account
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size (max 100)" default(20)
+// @Security ApiKeyAuth
+// @Success 200 {object} object{events=[]models.SecurityEvent,total=int,page=int,page_size=int}
+// @Failure 401 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/me/security-events [get]
+func (ah *AuthHandler) GetSecurityEvents(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	filter := repositories.SecurityEventFilter{UserID: uint(userID.(int))}
+	setEventsPage(c, &filter)
+
+	respondWithEvents(c, ah.Events, filter)
+}
+
+// GetSecurityEvents godoc
+// @Summary Query the security audit log
+// @Description Search the security events of all users, newest first. Available to admin and support roles.
+// @Tags admin
+// @Produce json
+// @Param user_id query int false "Account the event is about"
+// @Param type query string false "Event type" Enums(user.registered, login.succeeded, login.failed, password_reset.requested, password_reset.completed, password.changed, email.changed, email.verified, mfa.enabled, mfa.disabled, mfa.recovery_codes_regenerated, personal_access_token.created, personal_access_token.revoked, token.refreshed, token.revoked, role.changed)
+// @Param email query string false "Email of the account"
+// @Param ip query string false "Client IP"
+// @Param from query string false "Only events at or after this time (RFC 3339)"
+// @Param to query string false "Only events before this time (RFC 3339)"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size (max 100)" default(20)
+// @Security ApiKeyAuth
+// @Success 200 {object} object{events=[]models.SecurityEvent,total=int,page=int,page_size=int}
+// @Failure 400 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/admin/security-events [get]
+func (ah *AdminHandler) GetSecurityEvents(c *gin.Context) {
+	filter := repositories.SecurityEventFilter{
+		Type:  models.SecurityEventType(c.Query("type")),
+		Email: c.Query("email"),
+		IP:    c.Query("ip"),
+	}
+
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		filter.UserID = uint(id)
+	}
+
+	if filter.Type != "" && !models.IsValidSecurityEventType(filter.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event type"})
+		return
+	}
+
+	for param, field := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", use RFC 3339"})
+				return
+			}
+			*field = parsed
+		}
+	}
+
+	setEventsPage(c, &filter)
+
+	respondWithEvents(c, ah.auth.Events, filter)
+}
+
+func setEventsPage(c *gin.Context, filter *repositories.SecurityEventFilter) {
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultEventsPageSize)))
+	if filter.PageSize < 1 || filter.PageSize > maxEventsPageSize {
+		filter.PageSize = defaultEventsPageSize
+	}
+}
+
+func respondWithEvents(c *gin.Context, repo repositories.SecurityEventRepository, filter repositories.SecurityEventFilter) {
+	events, total, err := repo.SearchEvents(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching security events"})
+		return
+	}
+
+	if events == nil {
+		events = []models.SecurityEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"events":    events,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
+	})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	repo        repositories.SessionRepository
+	refreshRepo repositories.RefreshTokenRepository
+}
+
+func NewSessionHandler(repo repositories.SessionRepository, refreshRepo repositories.RefreshTokenRepository) *SessionHandler {
+	return &SessionHandler{repo: repo, refreshRepo: refreshRepo}
+}
+
+// GetSessions godoc
+// @Summary List sessions
+// @Description List the devices where the authenticated user is logged in, most recently active first
+// @Tags sessions
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.SessionDTO
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/sessions [get]
+func (sh *SessionHandler) GetSessions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	sessionID, _ := c.Get("sessionID")
+
+	sessions, err := sh.repo.GetSessionsByUserID(uint(userID.(int)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching sessions"})
+		return
+	}
+
+	sessionDTOs := []*models.SessionDTO{}
+	for _, session := range sessions {
+		sessionDTOs = append(sessionDTOs, session.ToDTO(sessionID.(string)))
+	}
+
+	c.JSON(http.StatusOK, sessionDTOs)
+}
+
+// DeleteSession godoc
+// @Summary End a session
+// @Description Log out a single device. Its refresh tokens are revoked and its access tokens stop being accepted.
+// @Tags sessions
+// @Produce json
+// @Param id path string true "Session ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/sessions/{id} [delete]
+func (sh *SessionHandler) DeleteSession(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id := c.Param("id")
+
+	if err := sh.repo.DeleteSession(id, uint(userID.(int))); err != nil {
+		if errors.Is(err, repositories.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting session"})
+		return
+	}
+
+	if err := sh.refreshRepo.RevokeFamily(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended successfully"})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/middleware"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestDB opens an in-memory SQLite database with every table migrated.
+// It has a single connection, since each connection to :memory: would see a
+// database of its own.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := models.MigrateAll(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// newTestAuthHandler wires an AuthHandler to the database the way the API
+// does, with password authentication and open registration.
+func newTestAuthHandler(t *testing.T, db *gorm.DB) *AuthHandler {
+	t.Helper()
+
+	keys, err := utils.LoadJWTKeySet()
+	if err != nil {
+		t.Fatalf("load JWT keys: %v", err)
+	}
+
+	authRepo := repositories.NewAuthRepository(db)
+	return &AuthHandler{
+		Repo:          authRepo,
+		RefreshRepo:   repositories.NewRefreshTokenRepository(db),
+		Revocations:   middleware.NewRevocationCache(repositories.NewRevocationRepository(db), time.Second),
+		Sessions:      repositories.NewSessionRepository(db),
+		UserTokens:    repositories.NewUserTokenRepository(db),
+		Recovery:      repositories.NewRecoveryCodeRepository(db),
+		Throttle:      NewLoginThrottle(repositories.NewMemoryLoginAttemptStore()),
+		Keys:          keys,
+		Invites:       repositories.NewInviteCodeRepository(db),
+		Events:        repositories.NewSecurityEventRepository(db),
+		Authenticator: NewPasswordAuthenticator(authRepo),
+		Registration:  RegistrationPolicy{Mode: RegistrationOpen},
+
+		PersonalAccessTokens: repositories.NewPersonalAccessTokenRepository(db),
+	}
+}
+
+// serve runs a request through the router and decodes the JSON response,
+// if there is one.
+func serve(router http.Handler, req *http.Request) (*httptest.ResponseRecorder, map[string]any) {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var body map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec, body
+}
+
+// eventsOf returns the types of the audit events recorded about the user,
+// oldest first.
+func eventsOf(t *testing.T, db *gorm.DB, userID uint) []models.SecurityEventType {
+	t.Helper()
+
+	var types []models.SecurityEventType
+	if err := db.Model(&models.SecurityEvent{}).Where("user_id = ?", userID).Order("id").Pluck("type", &types).Error; err != nil {
+		t.Fatalf("load events: %v", err)
+	}
+	return types
+}
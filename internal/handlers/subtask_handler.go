@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSubtasks godoc
+// @Summary List the subtasks of a task
+// @Description List the subtasks of a task. Their titles are unique within the task.
+// @Tags subtasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param render query string false "Set to html to add the sanitized HTML rendering of the Markdown description" Enums(html)
+// @Security ApiKeyAuth
+// @Success 200 {array} models.TaskDTO
+// @Failure 400 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tasks/{id}/subtasks [get]
+func (th *TaskHandler) GetSubtasks(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	parent, ok := th.findParentTask(c)
+	if !ok {
+		return
+	}
+
+	subtasks, err := th.repo.GetTasksByUserID(userID.(int), repositories.TaskFilter{ParentID: &parent.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching subtasks"})
+		return
+	}
+
+	taskDTOs := []*models.TaskDTO{}
+	for _, subtask := range subtasks {
+		taskDTOs = append(taskDTOs, taskDTO(c, &subtask))
+	}
+
+	c.JSON(http.StatusOK, taskDTOs)
+}
+
+// CreateSubtask godoc
+// @Summary Add a subtask
+// @Description Add a subtask to a top-level task. When priority or category_id are omitted, those of the task are used. Subtasks cannot recur or have subtasks of their own.
+// @Tags subtasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param subtask body models.TaskRequest true "Subtask creation data"
+// @Param render query string false "Set to html to add the sanitized HTML rendering of the Markdown description" Enums(html)
+// @Security ApiKeyAuth
+// @Success 201 {object} models.TaskDTO
+// @Failure 400 {object} object{error=string,details=object}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tasks/{id}/subtasks [post]
+func (th *TaskHandler) CreateSubtask(c *gin.Context) {
+	parent, ok := th.findParentTask(c)
+	if !ok {
+		return
+	}
+
+	th.createTask(c, parent)
+}
+
+// UpdateSubtask godoc
+// @Summary Update a subtask
+// @Description Update a subtask's details. Omitted dates are cleared.
+// @Tags subtasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param subtaskId path int true "Subtask ID"
+// @Param subtask body models.TaskRequest true "Subtask update data"
+// @Param render query string false "Set to html to add the sanitized HTML rendering of the Markdown description" Enums(html)
+// @Security ApiKeyAuth
+// @Success 200 {object} models.TaskDTO
+// @Failure 400 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tasks/{id}/subtasks/{subtaskId} [put]
+func (th *TaskHandler) UpdateSubtask(c *gin.Context) {
+	parent, subtask, ok := th.findSubtask(c)
+	if !ok {
+		return
+	}
+
+	th.updateTask(c, subtask, parent)
+}
+
+// DeleteSubtask godoc
+// @Summary Delete a subtask
+// @Description Permanently delete a subtask
+// @Tags subtasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param subtaskId path int true "Subtask ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tasks/{id}/subtasks/{subtaskId} [delete]
+func (th *TaskHandler) DeleteSubtask(c *gin.Context) {
+	_, subtask, ok := th.findSubtask(c)
+	if !ok {
+		return
+	}
+
+	th.deleteTask(c, int(subtask.ID))
+}
+
+// ToggleSubtask godoc
+// @Summary Toggle subtask status
+// @Description Toggle a subtask's completion status. The task is not completed along with its last subtask.
+// @Tags subtasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param subtaskId path int true "Subtask ID"
+// @Param render query string false "Set to html to add the sanitized HTML rendering of the Markdown description" Enums(html)
+// @Security ApiKeyAuth
+// @Success 200 {object} models.TaskDTO
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tasks/{id}/subtasks/{subtaskId}/complete [patch]
+func (th *TaskHandler) ToggleSubtask(c *gin.Context) {
+	_, subtask, ok := th.findSubtask(c)
+	if !ok {
+		return
+	}
+
+	th.toggleTask(c, int(subtask.ID))
+}
+
+// findParentTask loads the task of the request, which must be a top-level
+// task to have subtasks.
+func (th *TaskHandler) findParentTask(c *gin.Context) (*models.Task, bool) {
+	userID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	task, err := th.repo.GetTaskByID(id, userID.(int))
+	if err != nil {
+		if errors.Is(err, repositories.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching task"})
+		return nil, false
+	}
+
+	if task.ParentID != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subtasks cannot have subtasks"})
+		return nil, false
+	}
+	return task, true
+}
+
+func (th *TaskHandler) findSubtask(c *gin.Context) (*models.Task, *models.Task, bool) {
+	userID, _ := c.Get("userID")
+	parentID, _ := strconv.Atoi(c.Param("id"))
+	id, _ := strconv.Atoi(c.Param("subtaskId"))
+
+	subtask, err := th.repo.GetTaskByID(id, userID.(int))
+	if err != nil && !errors.Is(err, repositories.ErrTaskNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching subtask"})
+		return nil, nil, false
+	}
+	if err != nil || subtask.ParentID == 0 || subtask.ParentID != uint(parentID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subtask not found"})
+		return nil, nil, false
+	}
+
+	return &models.Task{ID: subtask.ParentID}, subtask, true
+}
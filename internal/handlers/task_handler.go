@@ -3,41 +3,70 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
 type TaskHandler struct {
-	repo repositories.TaskRepository
+	repo      repositories.TaskRepository
+	users     repositories.AuthRepository
+	reminders repositories.ReminderRepository
 }
 
-func NewTaskHandler(repo repositories.TaskRepository) *TaskHandler {
-	return &TaskHandler{repo: repo}
+func NewTaskHandler(repo repositories.TaskRepository, users repositories.AuthRepository, reminders repositories.ReminderRepository) *TaskHandler {
+	return &TaskHandler{repo: repo, users: users, reminders: reminders}
 }
 
 // GetTasks godoc
 // @Summary Get filtered tasks
-// @Description Get tasks with optional filters for category, status, and priority
+// @Description Get top-level tasks, with the progress of their subtasks, and optional filters for category, status, priority, due date and text. Relative due filters are evaluated in the user's time zone, with weeks starting on the user's week start day.
 // @Tags tasks
 // @Produce json
 // @Param categoryId query int false "Filter by category ID"
 // @Param status query boolean false "Filter by completion status (true/false)"
 // @Param priority query string false "Filter by priority (high/medium/low)" Enums(high, medium, low)
+// @Param due query string false "Incomplete tasks past due, tasks due today or this week, or tasks without a due date" Enums(overdue, today, week, none)
+// @Param dueFrom query string false "Only tasks due on or after this date (YYYY-MM-DD)"
+// @Param dueTo query string false "Only tasks due on or before this date (YYYY-MM-DD)"
+// @Param q query string false "Search the title and description of tasks and their subtasks"
+// @Param render query string false "Set to html to add the sanitized HTML rendering of the Markdown description" Enums(html)
 // @Security ApiKeyAuth
 // @Success 200 {array} models.TaskDTO
+// @Failure 400 {object} object{error=string}
 // @Failure 500 {object} object{error=string}
 // @Router /api/tasks [get]
 func (th *TaskHandler) GetTasks(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	categoryID := c.Query("categoryId")
-	status := c.Query("status")
-	priority := c.Query("priority")
+	var topLevel uint
+	filter := repositories.TaskFilter{
+		ParentID:   &topLevel,
+		CategoryID: c.Query("categoryId"),
+		Status:     c.Query("status"),
+		Priority:   c.Query("priority"),
+		Search:     c.Query("q"),
+		Due:        c.Query("due"),
+		DueFrom:    c.Query("dueFrom"),
+		DueTo:      c.Query("dueTo"),
+	}
+
+	if filter.Due != "" {
+		user, err := th.users.FindByID(uint(userID.(int)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user preferences"})
+			return
+		}
+		filter.Location = user.Location()
+		filter.WeekStart = time.Weekday(user.WeekStart)
+	}
 
-	tasks, err := th.repo.GetTasksByUserID(userID.(int), categoryID, status, priority)
+	tasks, err := th.repo.GetTasksByUserID(userID.(int), filter)
 	if err != nil {
 		if errors.Is(err, repositories.ErrInvalidFilter) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter parameter"})
@@ -49,7 +78,7 @@ func (th *TaskHandler) GetTasks(c *gin.Context) {
 
 	var taskDTOs []*models.TaskDTO
 	for _, task := range tasks {
-		taskDTOs = append(taskDTOs, task.ToDTO())
+		taskDTOs = append(taskDTOs, taskDTO(c, &task))
 	}
 
 	c.JSON(http.StatusOK, taskDTOs)
@@ -57,17 +86,25 @@ func (th *TaskHandler) GetTasks(c *gin.Context) {
 
 // CreateTask godoc
 // @Summary Create a new task
-// @Description Create a new task for the authenticated user
+// @Description Create a new task for the authenticated user. When priority or category_id are omitted, the user's default priority and category are used.
 // @Tags tasks
 // @Accept json
 // @Produce json
 // @Param task body models.TaskRequest true "Task creation data"
+// @Param render query string false "Set to html to add the sanitized HTML rendering of the Markdown description" Enums(html)
 // @Security ApiKeyAuth
 // @Success 201 {object} models.TaskDTO
 // @Failure 400 {object} object{error=string,details=object}
 // @Failure 500 {object} object{error=string}
 // @Router /api/tasks [post]
 func (th *TaskHandler) CreateTask(c *gin.Context) {
+	th.createTask(c, nil)
+}
+
+// createTask creates a task from the request, as a subtask of parent when
+// it is set. Subtasks take their priority and category from their parent
+// when omitted, and cannot recur.
+func (th *TaskHandler) createTask(c *gin.Context, parent *models.Task) {
 	var taskReq models.TaskRequest
 	userID, _ := c.Get("userID")
 
@@ -76,16 +113,39 @@ func (th *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
-	if err := models.ValidateTaskRequest(taskReq); err != nil {
-		validationErrors := models.GetTaskValidationMessages(err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Validation failed",
-			"details": validationErrors,
-		})
+	if parent != nil {
+		if taskReq.Priority == "" {
+			taskReq.Priority = parent.Priority
+		}
+		if taskReq.CategoryID == 0 {
+			taskReq.CategoryID = parent.CategoryID
+		}
+	}
+
+	if taskReq.Priority == "" || taskReq.CategoryID == 0 {
+		user, err := th.users.FindByID(uint(userID.(int)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user preferences"})
+			return
+		}
+		if taskReq.Priority == "" {
+			taskReq.Priority = user.DefaultPriority
+		}
+		if taskReq.CategoryID == 0 && user.DefaultCategoryID != nil {
+			taskReq.CategoryID = *user.DefaultCategoryID
+		}
+	}
+
+	if !th.validateTaskRequest(c, taskReq, parent) {
 		return
 	}
 
-	existingTask, err := th.repo.GetTaskByTitleAndUserID(taskReq.Title, userID.(int))
+	var parentID uint
+	if parent != nil {
+		parentID = parent.ID
+	}
+
+	existingTask, err := th.repo.GetTaskByTitleAndUserID(taskReq.Title, userID.(int), parentID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking task existence"})
 		return
@@ -96,11 +156,14 @@ func (th *TaskHandler) CreateTask(c *gin.Context) {
 	}
 
 	task := models.Task{
-		Title:      taskReq.Title,
-		Priority:   taskReq.Priority,
-		CategoryID: taskReq.CategoryID,
-		UserID:     uint(userID.(int)),
+		Title:       taskReq.Title,
+		Description: taskReq.Description,
+		Priority:    taskReq.Priority,
+		CategoryID:  taskReq.CategoryID,
+		UserID:      uint(userID.(int)),
+		ParentID:    parentID,
 	}
+	task.SetSchedule(taskReq)
 
 	newTask, err := th.repo.CreateTask(&task)
 	if err != nil {
@@ -112,17 +175,40 @@ func (th *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, newTask.ToDTO())
+	c.JSON(http.StatusCreated, taskDTO(c, newTask))
+}
+
+// validateTaskRequest responds with the validation errors of the request,
+// if any, for a task or a subtask of parent.
+func (th *TaskHandler) validateTaskRequest(c *gin.Context, taskReq models.TaskRequest, parent *models.Task) bool {
+	validationErrors := map[string][]string{}
+	err := models.ValidateTaskRequest(taskReq)
+	if err != nil {
+		validationErrors = models.GetTaskValidationMessages(err)
+	}
+	if parent != nil && taskReq.Recurrence != "" {
+		validationErrors["recurrence"] = append(validationErrors["recurrence"], "Subtasks cannot recur")
+	}
+
+	if err != nil || len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": validationErrors,
+		})
+		return false
+	}
+	return true
 }
 
 // UpdateTask godoc
 // @Summary Update a task
-// @Description Update an existing task's details
+// @Description Update an existing task's details. Omitted dates are cleared.
 // @Tags tasks
 // @Accept json
 // @Produce json
 // @Param id path int true "Task ID"
 // @Param task body models.TaskRequest true "Task update data"
+// @Param render query string false "Set to html to add the sanitized HTML rendering of the Markdown description" Enums(html)
 // @Security ApiKeyAuth
 // @Success 200 {object} models.TaskDTO
 // @Failure 400 {object} object{error=string}
@@ -143,31 +229,40 @@ func (th *TaskHandler) UpdateTask(c *gin.Context) {
 		return
 	}
 
+	var parent *models.Task
+	if existingTask.ParentID != 0 {
+		parent = &models.Task{ID: existingTask.ParentID}
+	}
+	th.updateTask(c, existingTask, parent)
+}
+
+// updateTask applies the request to the task, a subtask of parent when it
+// is set.
+func (th *TaskHandler) updateTask(c *gin.Context, existingTask *models.Task, parent *models.Task) {
 	var taskReq models.TaskRequest
 	if err := c.ShouldBindJSON(&taskReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task data"})
 		return
 	}
 
-	if err := models.ValidateTaskRequest(taskReq); err != nil {
-		validationErrors := models.GetTaskValidationMessages(err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Validation failed",
-			"details": validationErrors,
-		})
+	if !th.validateTaskRequest(c, taskReq, parent) {
 		return
 	}
 
 	if existingTask.Title == taskReq.Title &&
+		existingTask.Description == taskReq.Description &&
 		existingTask.Priority == taskReq.Priority &&
-		existingTask.CategoryID == taskReq.CategoryID {
+		existingTask.CategoryID == taskReq.CategoryID &&
+		existingTask.SameSchedule(taskReq) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes detected"})
 		return
 	}
 
 	existingTask.Title = taskReq.Title
+	existingTask.Description = taskReq.Description
 	existingTask.Priority = taskReq.Priority
 	existingTask.CategoryID = taskReq.CategoryID
+	existingTask.SetSchedule(taskReq)
 
 	updatedTask, err := th.repo.UpdateTask(existingTask)
 	if err != nil {
@@ -178,13 +273,14 @@ func (th *TaskHandler) UpdateTask(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating task"})
 		return
 	}
+	th.rescheduleReminders(updatedTask)
 
-	c.JSON(http.StatusOK, updatedTask.ToDTO())
+	c.JSON(http.StatusOK, taskDTO(c, updatedTask))
 }
 
 // DeleteTask godoc
 // @Summary Delete a task
-// @Description Permanently delete a task
+// @Description Permanently delete a task, along with its subtasks
 // @Tags tasks
 // @Produce json
 // @Param id path int true "Task ID"
@@ -194,8 +290,12 @@ func (th *TaskHandler) UpdateTask(c *gin.Context) {
 // @Failure 500 {object} object{error=string}
 // @Router /api/tasks/{id} [delete]
 func (th *TaskHandler) DeleteTask(c *gin.Context) {
-	userID, _ := c.Get("userID")
 	id, _ := strconv.Atoi(c.Param("id"))
+	th.deleteTask(c, id)
+}
+
+func (th *TaskHandler) deleteTask(c *gin.Context, id int) {
+	userID, _ := c.Get("userID")
 
 	err := th.repo.DeleteTask(id, userID.(int))
 	if err != nil {
@@ -212,18 +312,23 @@ func (th *TaskHandler) DeleteTask(c *gin.Context) {
 
 // ToggleTask godoc
 // @Summary Toggle task status
-// @Description Toggle a task's completion status
+// @Description Toggle a task's completion status. Completing a task completes its subtasks; reopening it leaves them as they are. Completing a recurring task moves its due date to the next occurrence and keeps it open with its subtasks reopened, until the series ends.
 // @Tags tasks
 // @Produce json
 // @Param id path int true "Task ID"
+// @Param render query string false "Set to html to add the sanitized HTML rendering of the Markdown description" Enums(html)
 // @Security ApiKeyAuth
 // @Success 200 {object} models.TaskDTO
 // @Failure 404 {object} object{error=string}
 // @Failure 500 {object} object{error=string}
 // @Router /api/tasks/{id}/complete [patch]
 func (th *TaskHandler) ToggleTask(c *gin.Context) {
-	userID, _ := c.Get("userID")
 	id, _ := strconv.Atoi(c.Param("id"))
+	th.toggleTask(c, id)
+}
+
+func (th *TaskHandler) toggleTask(c *gin.Context, id int) {
+	userID, _ := c.Get("userID")
 
 	task, err := th.repo.ToggleTaskStatus(id, userID.(int))
 	if err != nil {
@@ -234,6 +339,30 @@ func (th *TaskHandler) ToggleTask(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error toggling task status"})
 		return
 	}
+	th.rescheduleReminders(task)
 
-	c.JSON(http.StatusOK, task.ToDTO())
+	c.JSON(http.StatusOK, taskDTO(c, task))
+}
+
+// taskDTO converts the task for the response, rendering its description
+// as HTML when the request asks for it with render=html.
+func taskDTO(c *gin.Context, task *models.Task) *models.TaskDTO {
+	dto := task.ToDTO()
+	if c.Query("render") == "html" && task.Description != "" {
+		html, err := utils.RenderMarkdown(task.Description)
+		if err != nil {
+			log.Printf("Could not render the description of task %d: %v", task.ID, err)
+		} else {
+			dto.DescriptionHTML = html
+		}
+	}
+	return dto
+}
+
+// rescheduleReminders moves the reminders of the task along with its due
+// date. A failure is logged rather than failing the change to the task.
+func (th *TaskHandler) rescheduleReminders(task *models.Task) {
+	if err := th.reminders.RescheduleTaskReminders(task, task.User.Location()); err != nil {
+		log.Printf("Could not reschedule the reminders of task %d: %v", task.ID, err)
+	}
 }
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type TokenHandler struct {
+	repo repositories.PersonalAccessTokenRepository
+	auth *AuthHandler
+}
+
+func NewTokenHandler(repo repositories.PersonalAccessTokenRepository, auth *AuthHandler) *TokenHandler {
+	return &TokenHandler{repo: repo, auth: auth}
+}
+
+// GetTokens godoc
+// @Summary List personal access tokens
+// @Description List the active personal access tokens of the authenticated user. Token values are never returned again after creation.
+// @Tags tokens
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.PersonalAccessTokenDTO
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tokens [get]
+func (th *TokenHandler) GetTokens(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	tokens, err := th.repo.GetTokensByUserID(uint(userID.(int)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching tokens"})
+		return
+	}
+
+	tokenDTOs := []*models.PersonalAccessTokenDTO{}
+	for _, token := range tokens {
+		tokenDTOs = append(tokenDTOs, token.ToDTO())
+	}
+
+	c.JSON(http.StatusOK, tokenDTOs)
+}
+
+// CreateToken godoc
+// @Summary Create a personal access token
+// @Description Create a named, scoped token for scripts and integrations. Available scopes are tasks:read, tasks:write and categories:read. The token value is only returned in this response.
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Param token body models.PersonalAccessTokenRequest true "Token data"
+// @Security ApiKeyAuth
+// @Success 201 {object} object{token=string,details=models.PersonalAccessTokenDTO}
+// @Failure 400 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tokens [post]
+func (th *TokenHandler) CreateToken(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req models.PersonalAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token data"})
+		return
+	}
+
+	if !models.ValidateScopes(req.Scopes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope"})
+		return
+	}
+
+	plain, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+
+	token := models.PersonalAccessToken{
+		UserID:    uint(userID.(int)),
+		Name:      req.Name,
+		TokenHash: utils.HashToken(plain),
+		Prefix:    models.DisplayPrefix(plain),
+	}
+	token.SetScopes(req.Scopes)
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := th.repo.CreateToken(&token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create token"})
+		return
+	}
+
+	th.auth.recordEvent(c, models.SecurityEventAccessTokenCreated, th.currentUser(c), strconv.Itoa(int(token.ID))+": "+token.Name)
+
+	c.JSON(http.StatusCreated, gin.H{"token": plain, "details": token.ToDTO()})
+}
+
+// UpdateToken godoc
+// @Summary Update a personal access token
+// @Description Rename a personal access token or change its scopes
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Param id path int true "Token ID"
+// @Param token body models.PersonalAccessTokenUpdateRequest true "Token data"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.PersonalAccessTokenDTO
+// @Failure 400 {object} object{error=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tokens/{id} [put]
+func (th *TokenHandler) UpdateToken(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var req models.PersonalAccessTokenUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token data"})
+		return
+	}
+
+	if !models.ValidateScopes(req.Scopes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope"})
+		return
+	}
+
+	token, err := th.repo.GetTokenByID(id, uint(userID.(int)))
+	if err != nil {
+		if errors.Is(err, repositories.ErrPersonalAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching token"})
+		return
+	}
+
+	token.Name = req.Name
+	token.SetScopes(req.Scopes)
+	if err := th.repo.UpdateToken(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, token.ToDTO())
+}
+
+// DeleteToken godoc
+// @Summary Revoke a personal access token
+// @Description Revoke a personal access token; it stops working immediately
+// @Tags tokens
+// @Produce json
+// @Param id path int true "Token ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} object{message=string}
+// @Failure 404 {object} object{error=string}
+// @Failure 500 {object} object{error=string}
+// @Router /api/v1/tokens/{id} [delete]
+func (th *TokenHandler) DeleteToken(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := th.repo.RevokeToken(id, uint(userID.(int))); err != nil {
+		if errors.Is(err, repositories.ErrPersonalAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+		return
+	}
+
+	th.auth.recordEvent(c, models.SecurityEventAccessTokenRevoked, th.currentUser(c), strconv.Itoa(id))
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Token %d revoked successfully", id)})
+}
+
+// currentUser identifies the authenticated user in the audit log.
+func (th *TokenHandler) currentUser(c *gin.Context) *models.User {
+	userID, _ := c.Get("userID")
+	return &models.User{ID: uint(userID.(int)), Email: c.GetString("userEmail")}
+}
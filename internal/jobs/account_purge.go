@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+)
+
+// PurgeDeletedAccounts permanently removes accounts whose deletion grace
+// period is over, checking every interval until ctx is done. Purging is
+// idempotent, so it is safe to run on every replica.
+func PurgeDeletedAccounts(ctx context.Context, users repositories.AuthRepository, grace, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := users.PurgeDeletedUsers(time.Now().Add(-grace))
+		if err != nil {
+			log.Printf("Could not purge deleted accounts: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted accounts", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+)
+
+// RotateJWTKeys reloads the signing keys every interval, picking up keys
+// rotated by other replicas, and rotates them when due until ctx is done.
+func RotateJWTKeys(ctx context.Context, keys *utils.JWTKeySet, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := keys.Reload(); err != nil {
+			log.Printf("Could not reload JWT keys: %v", err)
+			continue
+		}
+		if err := keys.Rotate(); err != nil {
+			log.Printf("Could not rotate JWT keys: %v", err)
+		}
+	}
+}
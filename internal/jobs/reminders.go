@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/repositories"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// reminderBatchSize is how many due reminders are sent per check.
+const reminderBatchSize = 100
+
+// SendDueReminders emails the reminders that are due, checking every
+// interval until ctx is done. Each reminder is claimed for lease right before
+// it is sent, so replicas running the job do not send the same reminder; the
+// lease must be longer than utils.EmailTimeout. A reminder whose email
+// failed, or whose replica died while sending it, is sent again once its
+// claim expires.
+func SendDueReminders(ctx context.Context, reminders repositories.ReminderRepository, interval, lease time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		due, err := reminders.GetDueReminders(time.Now(), reminderBatchSize)
+		if err != nil {
+			log.Printf("Could not fetch due reminders: %v", err)
+		}
+
+		for _, reminder := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			sendReminder(reminders, reminder, lease)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendReminder claims the reminder as of now, since sending the earlier
+// reminders of the batch may have taken longer than the lease.
+func sendReminder(reminders repositories.ReminderRepository, reminder models.Reminder, lease time.Duration) {
+	token := uuid.NewString()
+	now := time.Now()
+	claimed, err := reminders.ClaimReminder(reminder.ID, token, now, now.Add(lease))
+	if err != nil {
+		log.Printf("Could not claim reminder %d: %v", reminder.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	task := reminder.Task
+	user := task.User
+	var due string
+	if dueAt, ok := task.DueAt(user.Location()); ok {
+		if task.DueTime != nil {
+			due = utils.FormatEmailTime(dueAt, user.Location())
+		} else {
+			due = *task.DueDate
+		}
+	}
+
+	if err := utils.SendTaskReminderEmail(user.Email, user.Locale, task.Title, due); err != nil {
+		log.Printf("Could not send reminder %d, retrying in %s: %v", reminder.ID, lease, err)
+		return
+	}
+
+	if err := reminders.MarkReminderSent(reminder.ID, token); err != nil {
+		log.Printf("Could not mark reminder %d as sent: %v", reminder.ID, err)
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// InviteCode lets people register while registration is invite-only. Like
+// personal access tokens, only the SHA-256 hash of the code is stored, along
+// with a short prefix so administrators can tell codes apart.
+type InviteCode struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CreatedByID uint       `gorm:"not null;index" json:"created_by_id"`
+	Note        string     `gorm:"size:255" json:"note"`
+	CodeHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Prefix      string     `gorm:"size:20" json:"prefix"`
+	MaxUses     int        `gorm:"not null;default:1" json:"max_uses"`
+	Uses        int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// InviteCodeRequest represents the payload for minting an invite code. Codes
+// without expires_in_days never expire.
+type InviteCodeRequest struct {
+	Note          string `json:"note" binding:"max=255" example:"Marketing team"`
+	MaxUses       int    `json:"max_uses" binding:"omitempty,min=1,max=10000" example:"10"`
+	ExpiresInDays *int   `json:"expires_in_days" binding:"omitempty,min=1,max=365" example:"14"`
+}
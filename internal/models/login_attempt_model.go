@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LoginAttempt counts the recent failed logins of one subject, either an
+// account ("account:<email>") or a client address ("ip:<address>").
+type LoginAttempt struct {
+	Subject      string    `gorm:"primaryKey;size:191" json:"subject"`
+	Failures     int       `gorm:"not null;default:0" json:"failures"`
+	LastFailedAt time.Time `gorm:"index" json:"last_failed_at"`
+}
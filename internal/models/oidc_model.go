@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at an OpenID Connect provider,
+// identified by the provider's sub claim.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Provider  string    `gorm:"size:50;not null;uniqueIndex:idx_provider_subject" json:"provider"`
+	Subject   string    `gorm:"size:191;not null;uniqueIndex:idx_provider_subject" json:"-"`
+	Email     string    `gorm:"size:255" json:"email"`
+}
+
+// OIDCLoginState remembers an authorization request sent to a provider until
+// its callback arrives. It is looked up by the SHA-256 hash of the state
+// parameter and used once.
+type OIDCLoginState struct {
+	StateHash    string `gorm:"size:64;primaryKey"`
+	CreatedAt    time.Time
+	Provider     string    `gorm:"size:50;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	Nonce        string    `gorm:"size:64;not null"`
+	DeviceName   string    `gorm:"size:100"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
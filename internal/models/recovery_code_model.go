@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time code that can replace a TOTP code when the user
+// has lost their authenticator. Only the SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+func MigrateRecoveryCodes(db *gorm.DB) error {
+	return db.AutoMigrate(&RecoveryCode{})
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a server-side record of an issued refresh token. Only the
+// SHA-256 hash of the token is stored. Tokens that are rotated from the same
+// login share a FamilyID, which is the ID of the login's Session, so a
+// replayed token can revoke the whole chain.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID  string     `gorm:"size:36;not null;index" json:"family_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+func (rt *RefreshToken) IsExpired() bool {
+	return time.Now().After(rt.ExpiresAt)
+}
+
+func MigrateRefreshTokens(db *gorm.DB) error {
+	return db.AutoMigrate(&RefreshToken{})
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReminderDefaultTime is when a task without a due time is considered due
+// for the reminders set relative to its due date.
+const ReminderDefaultTime = "09:00"
+
+// Reminder notifies the owner of a task by email at RemindAt, or
+// OffsetMinutes before the task is due. FireAt is the resulting time, kept
+// up to date when the due date changes or the reminder is snoozed.
+//
+// SentAt is set once the email went out, so restarts do not send it again.
+// A replica claims a reminder with ClaimToken until ClaimedUntil before
+// sending it; if it dies before marking it sent, the claim expires and
+// another replica sends it, so delivery is at least once.
+type Reminder struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	TaskID        uint       `gorm:"not null;index" json:"task_id"`
+	UserID        uint       `gorm:"not null;index" json:"-"`
+	RemindAt      *time.Time `json:"remind_at"`
+	OffsetMinutes *int       `json:"offset_minutes"`
+	FireAt        time.Time  `gorm:"not null;index" json:"fire_at"`
+	SentAt        *time.Time `json:"sent_at"`
+	DismissedAt   *time.Time `json:"dismissed_at"`
+	ClaimToken    string     `gorm:"size:36" json:"-"`
+	ClaimedUntil  *time.Time `json:"-"`
+	Task          Task       `gorm:"foreignKey:TaskID" json:"-"`
+}
+
+// ReminderRequest sets a reminder either at an absolute time or a number of
+// minutes before the task is due, but not both.
+type ReminderRequest struct {
+	RemindAt      *time.Time `json:"remind_at" example:"2025-04-02T09:00:00Z"`
+	OffsetMinutes *int       `json:"offset_minutes" example:"30"`
+}
+
+// SnoozeRequest postpones a reminder by Minutes from now.
+type SnoozeRequest struct {
+	Minutes int `json:"minutes" binding:"required,min=1,max=10080" example:"10"`
+}
+
+// Schedule computes FireAt for the task, whose dates are in loc. It returns
+// false for a reminder relative to the due date of a task that has none.
+func (r *Reminder) Schedule(task *Task, loc *time.Location) bool {
+	if r.RemindAt != nil {
+		remindAt := r.RemindAt.UTC()
+		r.RemindAt = &remindAt
+		r.FireAt = remindAt
+		return true
+	}
+	due, ok := task.DueAt(loc)
+	if !ok || r.OffsetMinutes == nil {
+		return false
+	}
+	r.FireAt = due.Add(-time.Duration(*r.OffsetMinutes) * time.Minute).UTC()
+	return true
+}
+
+func MigrateReminders(db *gorm.DB) error {
+	return db.AutoMigrate(&Reminder{})
+}
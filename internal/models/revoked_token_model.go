@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevokedToken blocks a single access token, identified by its jti claim,
+// until the token would have expired anyway.
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	JTI       string    `gorm:"size:36;not null;uniqueIndex" json:"jti"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func MigrateRevokedTokens(db *gorm.DB) error {
+	return db.AutoMigrate(&RevokedToken{})
+}
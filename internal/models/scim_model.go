@@ -0,0 +1,88 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// Schema URNs of the SCIM 2.0 resources and messages (RFC 7643, RFC 7644).
+const (
+	SCIMSchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMSchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMSchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// SCIMUser is a user as represented by the SCIM API. userName is the email
+// address of the user.
+type SCIMUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        *SCIMName   `json:"name,omitempty"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []SCIMEmail `json:"emails,omitempty"`
+	Locale      string      `json:"locale,omitempty"`
+	Timezone    string      `json:"timezone,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Meta        *SCIMMeta   `json:"meta,omitempty"`
+}
+
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    []*SCIMUser `json:"Resources"`
+}
+
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// ToSCIM returns the SCIM representation of the user, served at location.
+// Users are active unless suspended.
+func (u *User) ToSCIM(location string) *SCIMUser {
+	active := !u.IsSuspended()
+	return &SCIMUser{
+		Schemas:     []string{SCIMSchemaUser},
+		ID:          strconv.FormatUint(uint64(u.ID), 10),
+		ExternalID:  u.ExternalID,
+		UserName:    u.Email,
+		Name:        &SCIMName{Formatted: u.Name},
+		DisplayName: u.Name,
+		Emails:      []SCIMEmail{{Value: u.Email, Type: "work", Primary: true}},
+		Locale:      u.Locale,
+		Timezone:    u.TimeZone,
+		Active:      &active,
+		Meta: &SCIMMeta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     location,
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SecurityEventType string
+
+// Types of the events recorded in the security audit log.
+const (
+	SecurityEventRegistered               SecurityEventType = "user.registered"
+	SecurityEventLoginSucceeded           SecurityEventType = "login.succeeded"
+	SecurityEventLoginFailed              SecurityEventType = "login.failed"
+	SecurityEventPasswordResetRequested   SecurityEventType = "password_reset.requested"
+	SecurityEventPasswordResetCompleted   SecurityEventType = "password_reset.completed"
+	SecurityEventPasswordChanged          SecurityEventType = "password.changed"
+	SecurityEventEmailChanged             SecurityEventType = "email.changed"
+	SecurityEventEmailVerified            SecurityEventType = "email.verified"
+	SecurityEventMFAEnabled               SecurityEventType = "mfa.enabled"
+	SecurityEventMFADisabled              SecurityEventType = "mfa.disabled"
+	SecurityEventRecoveryCodesRegenerated SecurityEventType = "mfa.recovery_codes_regenerated"
+	SecurityEventAccessTokenCreated       SecurityEventType = "personal_access_token.created"
+	SecurityEventAccessTokenRevoked       SecurityEventType = "personal_access_token.revoked"
+	SecurityEventTokenRefreshed           SecurityEventType = "token.refreshed"
+	SecurityEventTokenRevoked             SecurityEventType = "token.revoked"
+	SecurityEventRoleChanged              SecurityEventType = "role.changed"
+)
+
+var SecurityEventTypes = []SecurityEventType{
+	SecurityEventRegistered,
+	SecurityEventLoginSucceeded,
+	SecurityEventLoginFailed,
+	SecurityEventPasswordResetRequested,
+	SecurityEventPasswordResetCompleted,
+	SecurityEventPasswordChanged,
+	SecurityEventEmailChanged,
+	SecurityEventEmailVerified,
+	SecurityEventMFAEnabled,
+	SecurityEventMFADisabled,
+	SecurityEventRecoveryCodesRegenerated,
+	SecurityEventAccessTokenCreated,
+	SecurityEventAccessTokenRevoked,
+	SecurityEventTokenRefreshed,
+	SecurityEventTokenRevoked,
+	SecurityEventRoleChanged,
+}
+
+// SecurityEvent is an entry of the append-only audit log. UserID is the
+// account the event is about, if one is known, and ActorID the authenticated
+// user who caused it, which differs for administrator actions. Detail holds
+// the reason of a failure, the login method or the change made.
+type SecurityEvent struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time         `gorm:"index" json:"created_at"`
+	Type      SecurityEventType `gorm:"size:50;not null;index" json:"type"`
+	UserID    *uint             `gorm:"index" json:"user_id"`
+	ActorID   *uint             `json:"actor_id"`
+	Email     string            `gorm:"size:255;index" json:"email"`
+	Detail    string            `gorm:"size:100" json:"detail"`
+	IP        string            `gorm:"size:45" json:"ip"`
+	UserAgent string            `gorm:"size:512" json:"user_agent"`
+}
+
+func IsValidSecurityEventType(t SecurityEventType) bool {
+	for _, valid := range SecurityEventTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func MigrateSecurityEvents(db *gorm.DB) error {
+	return db.AutoMigrate(&SecurityEvent{})
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session represents one login of a user on a device. Its ID is carried in
+// the sid claim of access tokens and is the family of its refresh tokens.
+type Session struct {
+	ID         string    `gorm:"primaryKey;size:36" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	DeviceName string    `gorm:"size:100" json:"device_name"`
+	UserAgent  string    `gorm:"size:512" json:"user_agent"`
+	IP         string    `gorm:"size:45" json:"ip"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type SessionDTO struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+func (s *Session) ToDTO(currentID string) *SessionDTO {
+	return &SessionDTO{
+		ID:         s.ID,
+		DeviceName: s.DeviceName,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		Current:    s.ID == currentID,
+	}
+}
+
+func MigrateSessions(db *gorm.DB) error {
+	return db.AutoMigrate(&Session{})
+}
@@ -3,10 +3,29 @@ package models
 import "gorm.io/gorm"
 
 func MigrateAll(db *gorm.DB) error {
+	// Task titles became unique per parent task with subtasks.
+	if db.Migrator().HasIndex(&Task{}, "idx_user_title") {
+		if err := db.Migrator().DropIndex(&Task{}, "idx_user_title"); err != nil {
+			return err
+		}
+	}
+
 	err := db.AutoMigrate(
 		&User{},
 		&Task{},
 		&Category{},
+		&RefreshToken{},
+		&RevokedToken{},
+		&Session{},
+		&UserToken{},
+		&RecoveryCode{},
+		&PersonalAccessToken{},
+		&LoginAttempt{},
+		&UserIdentity{},
+		&OIDCLoginState{},
+		&InviteCode{},
+		&SecurityEvent{},
+		&Reminder{},
 	)
 
 	if db.Dialector.Name() == "mysql" {
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 )
@@ -25,72 +26,291 @@ const (
 //	    "updated_at": {type: "string", format: "date-time", example: "2025-03-27T14:45:46Z"},
 //	    "deleted_at": {type: "string", format: "date-time", example: "null", x-nullable: true},
 //	    "title": {type: "string", example: "Complete project report", minLength: 3, maxLength: 255},
+//	    "description": {type: "string", example: "Include the **Q1** figures", maxLength: 10000},
 //	    "priority": {type: "string", enum: ["high", "medium", "low"], example: "medium"},
 //	    "status": {type: "boolean", example: false},
 //	    "category_id": {type: "integer", example: 2},
 //	    "user_id": {type: "integer", example: 1},
+//	    "parent_id": {type: "integer", example: 0},
+//	    "due_date": {type: "string", format: "date", example: "2025-04-02", x-nullable: true},
+//	    "due_time": {type: "string", example: "17:30", x-nullable: true},
+//	    "start_date": {type: "string", format: "date", example: "2025-03-30", x-nullable: true},
+//	    "all_day": {type: "boolean", example: false},
+//	    "recurrence": {type: "string", example: "FREQ=WEEKLY;BYDAY=MO,TH"},
+//	    "repeat_from": {type: "string", enum: ["schedule", "completion"], example: "schedule"},
 //	    "category": {"$ref": "#/definitions/Category"},
 //	    "user": {"$ref": "#/definitions/User"}
 //	}
 //
 // )
 type Task struct {
-	ID         uint       `gorm:"primaryKey" json:"id"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	DeletedAt  *time.Time `gorm:"index" json:"deleted_at"`
-	Title      string     `gorm:"size:255;not null;uniqueIndex:idx_user_title" json:"title" validate:"required,min=3,max=255"`
-	Priority   Priority   `gorm:"type:varchar(10);default:'medium'" json:"priority"`
-	Status     bool       `gorm:"default:false" json:"status"`
-	CategoryID uint       `gorm:"not null" json:"category_id" validate:"required"`
-	UserID     uint       `gorm:"not null;uniqueIndex:idx_user_title" json:"user_id"`
-	Category   Category   `gorm:"foreignKey:CategoryID" json:"category"`
-	User       User       `gorm:"foreignKey:UserID" json:"user"`
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `gorm:"index" json:"deleted_at"`
+	Title     string     `gorm:"size:255;not null;uniqueIndex:idx_user_parent_title" json:"title" validate:"required,min=3,max=255"`
+	// Description is Markdown source.
+	Description string   `gorm:"type:text" json:"description"`
+	Priority    Priority `gorm:"type:varchar(10);default:'medium'" json:"priority"`
+	Status      bool     `gorm:"default:false" json:"status"`
+	CategoryID  uint     `gorm:"not null" json:"category_id" validate:"required"`
+	UserID      uint     `gorm:"not null;uniqueIndex:idx_user_parent_title" json:"user_id"`
+	// ParentID is the task a subtask belongs to, or 0 for top-level tasks,
+	// so titles are unique among the top-level tasks of a user and among the
+	// subtasks of each task. Subtasks cannot have subtasks of their own.
+	ParentID uint `gorm:"not null;default:0;index;uniqueIndex:idx_user_parent_title" json:"parent_id"`
+	// Dates are calendar dates (YYYY-MM-DD) and the due time a wall clock
+	// time (HH:MM), both in the time zone of the user, so they keep their
+	// meaning when the user travels or changes time zone.
+	DueDate   *string `gorm:"size:10;index" json:"due_date"`
+	DueTime   *string `gorm:"size:5" json:"due_time"`
+	StartDate *string `gorm:"size:10" json:"start_date"`
+	AllDay    bool    `gorm:"default:false" json:"all_day"`
+	// Recurrence is an RRULE (RFC 5545). Completing a recurring task moves
+	// it to its next occurrence instead, counted by Occurrence for COUNT.
+	Recurrence string     `gorm:"size:255" json:"recurrence"`
+	RepeatFrom RepeatFrom `gorm:"size:10;not null;default:'schedule'" json:"repeat_from"`
+	Occurrence int        `gorm:"not null;default:1" json:"occurrence"`
+	// Progress of the subtasks, loaded with the task.
+	SubtasksDone  int      `gorm:"-" json:"-"`
+	SubtasksTotal int      `gorm:"-" json:"-"`
+	Category      Category `gorm:"foreignKey:CategoryID" json:"category"`
+	User          User     `gorm:"foreignKey:UserID" json:"user"`
 }
 
+const (
+	DateLayout = "2006-01-02"
+	TimeLayout = "15:04"
+)
+
+// RepeatFrom tells where the next occurrence of a recurring task is counted
+// from: its due date, keeping a fixed schedule, or the day it was completed.
+type RepeatFrom string
+
+const (
+	RepeatFromSchedule   RepeatFrom = "schedule"
+	RepeatFromCompletion RepeatFrom = "completion"
+)
+
 // TaskRequest represents the payload for creating/updating a task
 // @SWG.Definition(
 //
 //	required: ["title", "category_id"],
 //	properties: {
 //	    "title": {type: "string", example: "Buy groceries", minLength: 3, maxLength: 100},
+//	    "description": {type: "string", example: "- Milk\n- Bread", maxLength: 10000},
 //	    "priority": {type: "string", enum: ["high", "medium", "low"], example: "medium"},
-//	    "category_id": {type: "integer", example: 3}
+//	    "category_id": {type: "integer", example: 3},
+//	    "due_date": {type: "string", format: "date", example: "2025-04-02"},
+//	    "due_time": {type: "string", example: "17:30"},
+//	    "start_date": {type: "string", format: "date", example: "2025-03-30"},
+//	    "all_day": {type: "boolean", example: false},
+//	    "recurrence": {type: "string", example: "FREQ=WEEKLY;BYDAY=MO,TH"},
+//	    "repeat_from": {type: "string", enum: ["schedule", "completion"], example: "schedule"}
 //	}
 //
 // )
 type TaskRequest struct {
-	Title      string   `json:"title" validate:"required,min=3,max=100"`
-	Priority   Priority `json:"priority" validate:"oneof=high medium low"`
-	CategoryID uint     `json:"category_id" validate:"required"`
+	Title       string     `json:"title" validate:"required,min=3,max=100"`
+	Description string     `json:"description" validate:"max=10000"`
+	Priority    Priority   `json:"priority" validate:"oneof=high medium low"`
+	CategoryID  uint       `json:"category_id" validate:"required"`
+	DueDate     string     `json:"due_date" validate:"omitempty,datetime=2006-01-02"`
+	DueTime     string     `json:"due_time" validate:"omitempty,datetime=15:04"`
+	StartDate   string     `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	AllDay      bool       `json:"all_day"`
+	Recurrence  string     `json:"recurrence" validate:"omitempty,max=255,rrule"`
+	RepeatFrom  RepeatFrom `json:"repeat_from" validate:"omitempty,oneof=schedule completion"`
 }
 
 type TaskDTO struct {
-	ID        uint        `json:"id"`
-	CreatedAt time.Time   `json:"created_at"`
-	Title     string      `json:"title"`
-	Priority  Priority    `json:"priority"`
-	Status    bool        `json:"status"`
-	Category  CategoryDTO `json:"category"`
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	// DescriptionHTML is the sanitized rendering of Description, only set
+	// when requested.
+	DescriptionHTML string     `json:"description_html,omitempty"`
+	Priority        Priority   `json:"priority"`
+	Status          bool       `json:"status"`
+	DueDate         *string    `json:"due_date"`
+	DueTime         *string    `json:"due_time"`
+	StartDate       *string    `json:"start_date"`
+	AllDay          bool       `json:"all_day"`
+	Recurrence      string     `json:"recurrence"`
+	RepeatFrom      RepeatFrom `json:"repeat_from"`
+	// ParentID is set on subtasks only.
+	ParentID      *uint       `json:"parent_id,omitempty"`
+	SubtasksDone  int         `json:"subtasks_done"`
+	SubtasksTotal int         `json:"subtasks_total"`
+	Category      CategoryDTO `json:"category"`
 }
 
 func (t *Task) ToDTO() *TaskDTO {
+	var parentID *uint
+	if t.ParentID != 0 {
+		parentID = &t.ParentID
+	}
 	return &TaskDTO{
-		ID:        t.ID,
-		CreatedAt: t.CreatedAt,
-		Title:     t.Title,
-		Priority:  t.Priority,
-		Status:    t.Status,
-		Category:  *t.Category.ToDTO(),
+		ID:            t.ID,
+		CreatedAt:     t.CreatedAt,
+		Title:         t.Title,
+		Description:   t.Description,
+		Priority:      t.Priority,
+		Status:        t.Status,
+		DueDate:       t.DueDate,
+		DueTime:       t.DueTime,
+		StartDate:     t.StartDate,
+		AllDay:        t.AllDay,
+		Recurrence:    t.Recurrence,
+		RepeatFrom:    t.RepeatFrom,
+		ParentID:      parentID,
+		SubtasksDone:  t.SubtasksDone,
+		SubtasksTotal: t.SubtasksTotal,
+		Category:      *t.Category.ToDTO(),
+	}
+}
+
+// SetSchedule copies the dates and recurrence of the request to the task;
+// empty values clear them. Changing the rule starts a new series.
+func (t *Task) SetSchedule(taskReq TaskRequest) {
+	t.DueDate = optionalString(taskReq.DueDate)
+	t.DueTime = optionalString(taskReq.DueTime)
+	t.StartDate = optionalString(taskReq.StartDate)
+	t.AllDay = taskReq.AllDay
+	if t.Recurrence != taskReq.Recurrence {
+		t.Occurrence = 1
+	}
+	t.Recurrence = taskReq.Recurrence
+	t.RepeatFrom = taskReq.RepeatFrom
+	if t.RepeatFrom == "" {
+		t.RepeatFrom = RepeatFromSchedule
+	}
+}
+
+// SameSchedule reports whether the task already has the dates and
+// recurrence of the request.
+func (t *Task) SameSchedule(taskReq TaskRequest) bool {
+	repeatFrom := taskReq.RepeatFrom
+	if repeatFrom == "" {
+		repeatFrom = RepeatFromSchedule
+	}
+	return stringValue(t.DueDate) == taskReq.DueDate &&
+		stringValue(t.DueTime) == taskReq.DueTime &&
+		stringValue(t.StartDate) == taskReq.StartDate &&
+		t.AllDay == taskReq.AllDay &&
+		t.Recurrence == taskReq.Recurrence &&
+		t.RepeatFrom == repeatFrom
+}
+
+// DueAt returns the time the task is due in loc, taking tasks without a due
+// time as due at ReminderDefaultTime.
+func (t *Task) DueAt(loc *time.Location) (time.Time, bool) {
+	if t.DueDate == nil {
+		return time.Time{}, false
+	}
+	dueTime := ReminderDefaultTime
+	if t.DueTime != nil {
+		dueTime = *t.DueTime
+	}
+	due, err := time.ParseInLocation(DateLayout+" "+TimeLayout, *t.DueDate+" "+dueTime, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return due, true
+}
+
+// AdvanceRecurrence moves a recurring task to its next occurrence after
+// completion on the given day, shifting the start date along with the due
+// date. It returns false, leaving the task untouched, when the task does
+// not recur or its series has ended.
+func (t *Task) AdvanceRecurrence(today time.Time) bool {
+	if t.Recurrence == "" || t.DueDate == nil {
+		return false
+	}
+	rule, err := utils.ParseRecurrence(t.Recurrence)
+	if err != nil {
+		return false
+	}
+	if rule.Count > 0 && t.Occurrence >= rule.Count {
+		return false
+	}
+	due, err := time.Parse(DateLayout, *t.DueDate)
+	if err != nil {
+		return false
+	}
+
+	from := due
+	if t.RepeatFrom == RepeatFromCompletion {
+		from = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	next, ok := rule.Next(from, from)
+	if !ok {
+		return false
+	}
+
+	if t.StartDate != nil {
+		if start, err := time.Parse(DateLayout, *t.StartDate); err == nil {
+			shifted := next.Add(start.Sub(due)).Format(DateLayout)
+			t.StartDate = &shifted
+		}
 	}
+	nextDue := next.Format(DateLayout)
+	t.DueDate = &nextDue
+	t.Occurrence++
+	return true
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
 func ValidateTaskRequest(taskReq TaskRequest) error {
 	validate := validator.New()
 	validate.RegisterValidation("priority", validatePriority)
+	validate.RegisterValidation("rrule", validateRecurrence)
+	validate.RegisterStructValidation(validateTaskSchedule, TaskRequest{})
 	return validate.Struct(taskReq)
 }
 
+func validateRecurrence(fl validator.FieldLevel) bool {
+	_, err := utils.ParseRecurrence(fl.Field().String())
+	return err == nil
+}
+
+// validateTaskSchedule checks the dates against each other: a due time or a
+// recurrence needs a due date, a due time rules out all-day tasks, and a task
+// cannot start after it is due. Dates in the layout compare in calendar order as strings.
+func validateTaskSchedule(sl validator.StructLevel) {
+	taskReq := sl.Current().Interface().(TaskRequest)
+
+	if taskReq.DueTime != "" && taskReq.DueDate == "" {
+		sl.ReportError(taskReq.DueTime, "DueTime", "due_time", "requires_due_date", "")
+	}
+	if taskReq.DueTime != "" && taskReq.AllDay {
+		sl.ReportError(taskReq.AllDay, "AllDay", "all_day", "excludes_due_time", "")
+	}
+	if taskReq.AllDay && taskReq.DueDate == "" {
+		sl.ReportError(taskReq.AllDay, "AllDay", "all_day", "requires_due_date", "")
+	}
+	if taskReq.Recurrence != "" && taskReq.DueDate == "" {
+		sl.ReportError(taskReq.Recurrence, "Recurrence", "recurrence", "requires_due_date", "")
+	}
+	if taskReq.StartDate != "" && taskReq.DueDate != "" && taskReq.StartDate > taskReq.DueDate {
+		sl.ReportError(taskReq.StartDate, "StartDate", "start_date", "before_due_date", "")
+	}
+}
+
 func IsValidPriority(p Priority) bool {
 	switch p {
 	case High, Medium, Low:
@@ -126,10 +346,44 @@ func GetTaskValidationMessages(err error) map[string][]string {
 				case "min", "max":
 					errors["title"] = append(errors["title"], "Title must be between 3 and 100 characters")
 				}
+			case "Description":
+				errors["description"] = append(errors["description"], "Description must be at most 10000 characters")
 			case "Priority":
 				errors["priority"] = append(errors["priority"], "Priority must be one of: high, medium, low")
 			case "CategoryID":
 				errors["category_id"] = append(errors["category_id"], "Category is required")
+			case "DueDate":
+				errors["due_date"] = append(errors["due_date"], "Due date must be a date like 2025-04-02")
+			case "DueTime":
+				switch tag {
+				case "requires_due_date":
+					errors["due_time"] = append(errors["due_time"], "Due time requires a due date")
+				default:
+					errors["due_time"] = append(errors["due_time"], "Due time must be a 24-hour time like 17:30")
+				}
+			case "StartDate":
+				switch tag {
+				case "before_due_date":
+					errors["start_date"] = append(errors["start_date"], "Start date cannot be after the due date")
+				default:
+					errors["start_date"] = append(errors["start_date"], "Start date must be a date like 2025-03-30")
+				}
+			case "Recurrence":
+				switch tag {
+				case "requires_due_date":
+					errors["recurrence"] = append(errors["recurrence"], "Recurring tasks require a due date")
+				default:
+					errors["recurrence"] = append(errors["recurrence"], "Recurrence must be an RRULE like FREQ=WEEKLY;BYDAY=MO,TH")
+				}
+			case "RepeatFrom":
+				errors["repeat_from"] = append(errors["repeat_from"], "Repeat from must be one of: schedule, completion")
+			case "AllDay":
+				switch tag {
+				case "requires_due_date":
+					errors["all_day"] = append(errors["all_day"], "All-day tasks require a due date")
+				default:
+					errors["all_day"] = append(errors["all_day"], "All-day tasks cannot have a due time")
+				}
 			}
 		}
 	}
@@ -139,7 +393,7 @@ func GetTaskValidationMessages(err error) map[string][]string {
 
 func MigrateTasks(db *gorm.DB) error {
 
-	err := db.Migrator().CreateIndex(&Task{}, "idx_user_title")
+	err := db.Migrator().CreateIndex(&Task{}, "idx_user_parent_title")
 	if err != nil {
 		return err
 	}
@@ -1,9 +1,14 @@
 package models
 
 import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 	"unicode"
 
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 )
@@ -20,37 +25,209 @@ import (
 //		    "name": {type: "string", example: "John Doe", minLength: 2, maxLength: 50},
 //		    "email": {type: "string", format: "email", example: "user@example.com"},
 //		    "password": {type: "string", format: "password", example: "P@ssw0rd!", minLength: 8},
-//		    "is_verified": {type: "boolean", example: false},
-//		    "token": {type: "string", example: "550e8400-e29b-41d4-a716-446655440000"}
+//		    "is_verified": {type: "boolean", example: false}
 //		}
 //
 // )
 type User struct {
-	ID           uint       `gorm:"primaryKey" json:"id"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
-	DeletedAt    *time.Time `gorm:"index" json:"deleted_at"`
-	Name         string     `gorm:"size:255" json:"name" validate:"required,min=2,max=50"`
-	Email        string     `gorm:"unique;size:255" json:"email" validate:"required,email"`
-	Password     string     `gorm:"size:255" json:"password" validate:"required,min=8,password"`
-	IsVerified   bool       `gorm:"default:false" json:"is_verified"`
-	RefreshToken string     `gorm:"size:255" json:"refresh_token"`
-	Token        string     `gorm:"size:255" json:"token"`
-	ResetToken   string     `gorm:"size:255" json:"reset_token"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `gorm:"index" json:"deleted_at"`
+	Name       string     `gorm:"size:255" json:"name" validate:"required,min=2,max=50"`
+	Email      string     `gorm:"unique;size:255" json:"email" validate:"required,email"`
+	Password   string     `gorm:"size:255" json:"password" validate:"required,min=8,password"`
+	IsVerified bool       `gorm:"default:false" json:"is_verified"`
+	Role       Role       `gorm:"size:20;not null;default:'user'" json:"role"`
+	// SuspendedAt blocks the account from logging in or using any token.
+	SuspendedAt *time.Time `json:"suspended_at"`
+	// PasswordResetRequired blocks password logins until the user resets
+	// their password through the emailed link.
+	PasswordResetRequired bool `gorm:"default:false" json:"password_reset_required"`
+	// ExternalID is the identifier given to the user by the identity provider
+	// that provisions it through SCIM.
+	ExternalID string `gorm:"size:191;index" json:"-"`
+	// TokensRevokedAt invalidates every token issued before it.
+	TokensRevokedAt *time.Time `json:"-"`
+	// TOTPSecret is set when enrollment starts; TOTPEnabled only once the
+	// user confirmed it with a valid code.
+	TOTPSecret   string `gorm:"size:64" json:"-"`
+	TOTPEnabled  bool   `gorm:"default:false" json:"-"`
+	TOTPLastStep int64  `json:"-"`
+	// Profile and preferences.
+	AvatarURL         string   `gorm:"size:512" json:"avatar_url"`
+	TimeZone          string   `gorm:"size:64;not null;default:'UTC'" json:"time_zone"`
+	Locale            string   `gorm:"size:10;not null;default:'en'" json:"locale"`
+	WeekStart         int      `gorm:"not null;default:1" json:"week_start"`
+	DefaultPriority   Priority `gorm:"type:varchar(10);not null;default:'medium'" json:"default_priority"`
+	DefaultCategoryID *uint    `json:"default_category_id"`
+}
+
+type Role string
+
+const (
+	RoleUser    Role = "user"
+	RoleSupport Role = "support"
+	RoleAdmin   Role = "admin"
+)
+
+func IsValidRole(r Role) bool {
+	switch r {
+	case RoleUser, RoleSupport, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// Outranks reports whether r has more privileges than other. Admins outrank
+// support, which outranks regular users.
+func (r Role) Outranks(other Role) bool {
+	return r.rank() > other.rank()
+}
+
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 2
+	case RoleSupport:
+		return 1
+	default:
+		return 0
+	}
 }
 
 type UserDTO struct {
-	ID    uint   `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID                uint     `json:"id"`
+	Name              string   `json:"name"`
+	Email             string   `json:"email"`
+	Role              Role     `json:"role"`
+	MFAEnabled        bool     `json:"mfa_enabled"`
+	AvatarURL         string   `json:"avatar_url"`
+	TimeZone          string   `json:"time_zone"`
+	Locale            string   `json:"locale"`
+	WeekStart         int      `json:"week_start"`
+	DefaultPriority   Priority `json:"default_priority"`
+	DefaultCategoryID *uint    `json:"default_category_id"`
 }
 
 func (u *User) ToDTO() *UserDTO {
 	return &UserDTO{
-		ID:    u.ID,
-		Name:  u.Name,
-		Email: u.Email,
+		ID:                u.ID,
+		Name:              u.Name,
+		Email:             u.Email,
+		Role:              u.Role,
+		MFAEnabled:        u.TOTPEnabled,
+		AvatarURL:         u.AvatarURL,
+		TimeZone:          u.TimeZone,
+		Locale:            u.Locale,
+		WeekStart:         u.WeekStart,
+		DefaultPriority:   u.DefaultPriority,
+		DefaultCategoryID: u.DefaultCategoryID,
+	}
+}
+
+// Location returns the user's time zone, falling back to UTC.
+func (u *User) Location() *time.Location {
+	if u.TimeZone != "" {
+		if loc, err := time.LoadLocation(u.TimeZone); err == nil {
+			return loc
+		}
 	}
+	return time.UTC
+}
+
+// UserProfileRequest is a partial update of the profile: only the fields
+// present in the request are changed. The email is changed through its own
+// confirmation flow.
+type UserProfileRequest struct {
+	Name              *string   `json:"name" example:"John Doe"`
+	AvatarURL         *string   `json:"avatar_url" example:"https://example.com/avatar.png"`
+	TimeZone          *string   `json:"time_zone" example:"Europe/Madrid"`
+	Locale            *string   `json:"locale" example:"es"`
+	WeekStart         *int      `json:"week_start" example:"1"`
+	DefaultPriority   *Priority `json:"default_priority" example:"medium"`
+	DefaultCategoryID *uint     `json:"default_category_id" example:"2"`
+}
+
+// Validate checks the fields present in the request and returns the
+// messages per field, in the same shape as GetValidationMessages.
+func (r *UserProfileRequest) Validate(supportedLocales []string) map[string][]string {
+	errors := make(map[string][]string)
+
+	if r.Name != nil {
+		if length := len([]rune(strings.TrimSpace(*r.Name))); length < 2 || length > 50 {
+			errors["name"] = append(errors["name"], "Name must be between 2 and 50 characters")
+		}
+	}
+
+	if r.AvatarURL != nil && *r.AvatarURL != "" {
+		parsed, err := url.Parse(*r.AvatarURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(*r.AvatarURL) > 512 {
+			errors["avatar_url"] = append(errors["avatar_url"], "Avatar must be an http or https URL of at most 512 characters")
+		}
+	}
+
+	if r.TimeZone != nil {
+		if _, err := time.LoadLocation(*r.TimeZone); err != nil || *r.TimeZone == "" || *r.TimeZone == "Local" {
+			errors["time_zone"] = append(errors["time_zone"], "Time zone must be an IANA time zone name, like Europe/Madrid")
+		}
+	}
+
+	if r.Locale != nil && !slices.Contains(supportedLocales, *r.Locale) {
+		errors["locale"] = append(errors["locale"], "Locale must be one of: "+strings.Join(supportedLocales, ", "))
+	}
+
+	if r.WeekStart != nil && (*r.WeekStart < 0 || *r.WeekStart > 6) {
+		errors["week_start"] = append(errors["week_start"], "Week start must be a day from 0 (Sunday) to 6 (Saturday)")
+	}
+
+	if r.DefaultPriority != nil && !IsValidPriority(*r.DefaultPriority) {
+		errors["default_priority"] = append(errors["default_priority"], "Priority must be one of: high, medium, low")
+	}
+
+	return errors
+}
+
+// UserTaskCounts summarizes the tasks of a user for the admin API.
+type UserTaskCounts struct {
+	Total     int64 `json:"total"`
+	Completed int64 `json:"completed"`
+	Pending   int64 `json:"pending"`
+}
+
+type AdminUserDTO struct {
+	ID                    uint           `json:"id"`
+	CreatedAt             time.Time      `json:"created_at"`
+	Name                  string         `json:"name"`
+	Email                 string         `json:"email"`
+	Role                  Role           `json:"role"`
+	IsVerified            bool           `json:"is_verified"`
+	MFAEnabled            bool           `json:"mfa_enabled"`
+	SuspendedAt           *time.Time     `json:"suspended_at"`
+	PasswordResetRequired bool           `json:"password_reset_required"`
+	DeletedAt             *time.Time     `json:"deleted_at"`
+	Tasks                 UserTaskCounts `json:"tasks"`
+}
+
+func (u *User) ToAdminDTO(tasks UserTaskCounts) *AdminUserDTO {
+	return &AdminUserDTO{
+		ID:                    u.ID,
+		CreatedAt:             u.CreatedAt,
+		Name:                  u.Name,
+		Email:                 u.Email,
+		Role:                  u.Role,
+		IsVerified:            u.IsVerified,
+		MFAEnabled:            u.TOTPEnabled,
+		SuspendedAt:           u.SuspendedAt,
+		PasswordResetRequired: u.PasswordResetRequired,
+		DeletedAt:             u.DeletedAt,
+		Tasks:                 tasks,
+	}
+}
+
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
 }
 
 func GetValidationMessages(err error) map[string][]string {
@@ -69,14 +246,18 @@ func GetValidationMessages(err error) map[string][]string {
 				case "email":
 					errors["email"] = append(errors["email"], "Invalid email format")
 				}
-			case "Password":
+			case "Password", "NewPassword":
+				key := "password"
+				if field == "NewPassword" {
+					key = "new_password"
+				}
 				switch tag {
 				case "required":
-					errors["password"] = append(errors["password"], "Password is required")
+					errors[key] = append(errors[key], "Password is required")
 				case "min":
-					errors["password"] = append(errors["password"], "Password must be at least 8 characters")
+					errors[key] = append(errors[key], "Password must be at least 8 characters")
 				case "password":
-					errors["password"] = append(errors["password"], "Password must contain at least one uppercase letter, one lowercase letter, one number, and one special character")
+					errors[key] = append(errors[key], "Password must contain at least one uppercase letter, one lowercase letter, one number, and one special character")
 				}
 			case "Name":
 				switch tag {
@@ -118,6 +299,15 @@ func passwordValidator(fl validator.FieldLevel) bool {
 	return hasUpper && hasLower && hasNumber && hasSpecial
 }
 
+// RegisterBindingValidations makes the custom validations available to the
+// binding tags of request structs bound by gin.
+func RegisterBindingValidations() error {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		return v.RegisterValidation("password", passwordValidator)
+	}
+	return nil
+}
+
 func (u *User) Validate() error {
 	validate := validator.New()
 	validate.RegisterValidation("password", passwordValidator)
@@ -127,3 +317,43 @@ func (u *User) Validate() error {
 func MigrateUsers(db *gorm.DB) error {
 	return db.AutoMigrate(&User{})
 }
+
+// PromoteAdmin gives the admin role to the user with the given email, so a
+// deployment can bootstrap its first administrator, and reports whether it
+// did. It does nothing once any administrator exists, so demoting that user
+// later sticks, nor until the user has registered and verified the address.
+// The promotion is recorded in the audit log.
+func PromoteAdmin(db *gorm.DB, email string) (bool, error) {
+	promoted := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var admins int64
+		if err := tx.Model(&User{}).Where("role = ? AND deleted_at IS NULL", RoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins > 0 {
+			return nil
+		}
+
+		var user User
+		err := tx.Where("email = ? AND is_verified = ? AND deleted_at IS NULL", email, true).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&User{}).Where("id = ? AND role = ?", user.ID, user.Role).Update("role", RoleAdmin)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		promoted = true
+		return tx.Create(&SecurityEvent{
+			Type:   SecurityEventRoleChanged,
+			UserID: &user.ID,
+			Email:  user.Email,
+			Detail: string(user.Role) + " -> " + string(RoleAdmin) + " (ADMIN_EMAIL)",
+		}).Error
+	})
+	return promoted, err
+}
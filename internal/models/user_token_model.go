@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TokenPurpose string
+
+const (
+	TokenPurposeEmailVerification TokenPurpose = "email_verification"
+	TokenPurposePasswordReset     TokenPurpose = "password_reset"
+	TokenPurposeEmailChange       TokenPurpose = "email_change"
+	TokenPurposeMagicLink         TokenPurpose = "magic_link"
+)
+
+// UserToken is a single-use token sent to a user by email, such as an email
+// verification or password reset link. Only the SHA-256 hash is stored. Data
+// holds what the token confirms, like the new address for an email change.
+type UserToken struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UserID    uint         `gorm:"not null;index:idx_user_purpose" json:"user_id"`
+	Purpose   TokenPurpose `gorm:"size:32;not null;index:idx_user_purpose" json:"purpose"`
+	TokenHash string       `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Data      string       `gorm:"size:255" json:"-"`
+	ExpiresAt time.Time    `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time   `json:"used_at"`
+}
+
+func MigrateUserTokens(db *gorm.DB) error {
+	return db.AutoMigrate(&UserToken{})
+}
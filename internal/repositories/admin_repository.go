@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"strings"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Values accepted by UserFilter.Status.
+const (
+	UserStatusActive     = "active"
+	UserStatusSuspended  = "suspended"
+	UserStatusUnverified = "unverified"
+)
+
+type UserFilter struct {
+	Query    string
+	Role     models.Role
+	Status   string
+	Page     int
+	PageSize int
+}
+
+type AdminRepository interface {
+	SearchUsers(filter UserFilter) ([]models.User, int64, error)
+	GetTaskCounts(userIDs []uint) (map[uint]models.UserTaskCounts, error)
+	SetSuspended(userID uint, suspended bool) error
+	SetRole(userID uint, role models.Role) error
+	SetVerified(userID uint) error
+	RequirePasswordReset(userID uint) error
+}
+
+type adminRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminRepository(db *gorm.DB) AdminRepository {
+	return &adminRepository{db: db}
+}
+
+// SearchUsers returns a page of users matching the filter, ordered by ID,
+// along with the total number of matches.
+func (r *adminRepository) SearchUsers(filter UserFilter) ([]models.User, int64, error) {
+	query := r.db.Model(&models.User{})
+
+	if filter.Query != "" {
+		like := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!' OR LOWER(email) LIKE ? ESCAPE '!'", like, like)
+	}
+
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+
+	switch filter.Status {
+	case UserStatusActive:
+		query = query.Where("suspended_at IS NULL AND deleted_at IS NULL AND is_verified = ?", true)
+	case UserStatusSuspended:
+		query = query.Where("suspended_at IS NOT NULL")
+	case UserStatusUnverified:
+		query = query.Where("is_verified = ?", false)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	err := query.
+		Order("id ASC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&users).Error
+	return users, total, err
+}
+
+func (r *adminRepository) GetTaskCounts(userIDs []uint) (map[uint]models.UserTaskCounts, error) {
+	counts := make(map[uint]models.UserTaskCounts)
+	if len(userIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		UserID uint
+		Status bool
+		Count  int64
+	}
+	err := r.db.Model(&models.Task{}).
+		Select("user_id, status, COUNT(*) AS count").
+		Where("user_id IN ?", userIDs).
+		Group("user_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		entry := counts[row.UserID]
+		entry.Total += row.Count
+		if row.Status {
+			entry.Completed += row.Count
+		} else {
+			entry.Pending += row.Count
+		}
+		counts[row.UserID] = entry
+	}
+	return counts, nil
+}
+
+func (r *adminRepository) SetSuspended(userID uint, suspended bool) error {
+	var suspendedAt *time.Time
+	if suspended {
+		now := time.Now()
+		suspendedAt = &now
+	}
+	return r.updateUser(userID, "suspended_at", suspendedAt)
+}
+
+func (r *adminRepository) SetRole(userID uint, role models.Role) error {
+	return r.updateUser(userID, "role", role)
+}
+
+func (r *adminRepository) SetVerified(userID uint) error {
+	return r.updateUser(userID, "is_verified", true)
+}
+
+func (r *adminRepository) RequirePasswordReset(userID uint) error {
+	return r.updateUser(userID, "password_reset_required", true)
+}
+
+func (r *adminRepository) updateUser(userID uint, column string, value interface{}) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).Update(column, value).Error
+}
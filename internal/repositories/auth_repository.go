@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"errors"
+	"time"
 
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
@@ -15,11 +16,23 @@ var (
 )
 
 type AuthRepository interface {
+	FindByID(id uint) (*models.User, error)
 	FindByEmail(email string) (*models.User, error)
-	FindByToken(token string) (*models.User, error)
-	FindByResetToken(token string) (*models.User, error)
 	CreateUser(user *models.User) error
-	UpdateUser(user *models.User) error
+	UpdateUser(user *models.User, columns ...string) error
+	AdvanceTOTPStep(userID uint, step int64) (bool, error)
+	ListUsers(filter ProvisionedUserFilter) ([]models.User, int64, error)
+	PurgeDeletedUsers(deletedBefore time.Time) (int, error)
+}
+
+// ProvisionedUserFilter selects the users listed by the SCIM API. Empty fields
+// match any user; deleted users are never listed.
+type ProvisionedUserFilter struct {
+	Email      string
+	ExternalID string
+	Active     *bool
+	Offset     int
+	Limit      int
 }
 
 type authRepository struct {
@@ -30,9 +43,9 @@ func NewAuthRepository(db *gorm.DB) AuthRepository {
 	return &authRepository{db: db}
 }
 
-func (r *authRepository) FindByEmail(email string) (*models.User, error) {
+func (r *authRepository) FindByID(id uint) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.First(&user, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
@@ -42,24 +55,12 @@ func (r *authRepository) FindByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *authRepository) FindByToken(token string) (*models.User, error) {
-	var user models.User
-	err := r.db.Where("token = ?", token).First(&user).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrTokenNotFound
-		}
-		return nil, err
-	}
-	return &user, nil
-}
-
-func (r *authRepository) FindByResetToken(token string) (*models.User, error) {
+func (r *authRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("reset_token = ?", token).First(&user).Error
+	err := r.db.Where("email = ?", email).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrTokenNotFound
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -77,6 +78,128 @@ func (r *authRepository) CreateUser(user *models.User) error {
 	return nil
 }
 
-func (r *authRepository) UpdateUser(user *models.User) error {
-	return r.db.Save(user).Error
+// UpdateUser writes the given columns of the user, and only those, so a
+// stale copy of the user cannot undo a concurrent change to other columns,
+// like a suspension or a revocation.
+func (r *authRepository) UpdateUser(user *models.User, columns ...string) error {
+	if len(columns) == 0 {
+		return errors.New("no user columns to update")
+	}
+	return r.db.Model(user).Select(columns).Updates(user).Error
+}
+
+// ListUsers returns the users matching the filter, ordered by ID, along with
+// the total number of matches.
+// AdvanceTOTPStep records the time step of a used TOTP code, and reports
+// false if that step or a later one was already used. The check and the write
+// are a single update, so concurrent requests cannot use the same code twice.
+func (r *authRepository) AdvanceTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *authRepository) ListUsers(filter ProvisionedUserFilter) ([]models.User, int64, error) {
+	query := r.db.Model(&models.User{}).Where("deleted_at IS NULL")
+
+	if filter.Email != "" {
+		query = query.Where("email = ?", filter.Email)
+	}
+
+	if filter.ExternalID != "" {
+		query = query.Where("external_id = ?", filter.ExternalID)
+	}
+
+	if filter.Active != nil {
+		if *filter.Active {
+			query = query.Where("suspended_at IS NULL")
+		} else {
+			query = query.Where("suspended_at IS NOT NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	if filter.Limit == 0 {
+		return users, total, nil
+	}
+	err := query.
+		Order("id ASC").
+		Offset(filter.Offset).
+		Limit(filter.Limit).
+		Find(&users).Error
+	return users, total, err
+}
+
+// PurgeDeletedUsers permanently removes the users soft-deleted before the
+// given time together with everything that belongs to them, and returns how
+// many were removed. Their audit events are kept, but stripped of anything
+// that identifies them: only the type and time of the event remain.
+func (r *authRepository) PurgeDeletedUsers(deletedBefore time.Time) (int, error) {
+	var users []models.User
+	err := r.db.Select("id", "email").
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Find(&users).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for i, user := range users {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			for _, model := range []interface{}{
+				&models.Reminder{},
+				&models.Task{},
+				&models.RefreshToken{},
+				&models.RevokedToken{},
+				&models.Session{},
+				&models.UserToken{},
+				&models.RecoveryCode{},
+				&models.PersonalAccessToken{},
+				&models.UserIdentity{},
+			} {
+				if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("subject = ?", AccountSubject(user.Email)).Delete(&models.LoginAttempt{}).Error; err != nil {
+				return err
+			}
+			if err := anonymizeSecurityEvents(tx, user); err != nil {
+				return err
+			}
+			return tx.Delete(&models.User{}, user.ID).Error
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
+
+// anonymizeSecurityEvents removes the user from the audit log: from events
+// about them, including failed logins recorded under their address only, and
+// from events they caused as an administrator, whose IP and user agent are
+// theirs.
+func anonymizeSecurityEvents(tx *gorm.DB, user models.User) error {
+	err := tx.Model(&models.SecurityEvent{}).
+		Where("user_id = ? OR (user_id IS NULL AND email = ?)", user.ID, user.Email).
+		Updates(map[string]interface{}{
+			"user_id":    nil,
+			"email":      "",
+			"ip":         "",
+			"user_agent": "",
+			// The previous address of an email change.
+			"detail": gorm.Expr("CASE WHEN type = ? THEN '' ELSE detail END", models.SecurityEventEmailChanged),
+		}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.SecurityEvent{}).
+		Where("actor_id = ?", user.ID).
+		Updates(map[string]interface{}{"actor_id": nil, "ip": "", "user_agent": ""}).Error
 }
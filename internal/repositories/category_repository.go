@@ -14,6 +14,7 @@ var (
 
 type CategoryRepository interface {
 	GetAllCategories() ([]models.Category, error)
+	GetCategoryByID(id uint) (*models.Category, error)
 }
 
 type categoryRepository struct {
@@ -31,3 +32,14 @@ func (cr *categoryRepository) GetAllCategories() ([]models.Category, error) {
 	}
 	return categories, nil
 }
+
+func (cr *categoryRepository) GetCategoryByID(id uint) (*models.Category, error) {
+	var category models.Category
+	if err := cr.db.First(&category, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("error fetching category: %w", err)
+	}
+	return &category, nil
+}
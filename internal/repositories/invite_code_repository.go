@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInviteCodeNotFound = errors.New("invite code not found")
+	ErrInviteCodeInvalid  = errors.New("invite code invalid")
+)
+
+type InviteCodeRepository interface {
+	CreateInviteCode(invite *models.InviteCode) error
+	GetInviteCodes() ([]models.InviteCode, error)
+	RevokeInviteCode(id int) error
+	RedeemInviteCode(code string) error
+	ReleaseInviteCode(code string) error
+}
+
+type inviteCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewInviteCodeRepository(db *gorm.DB) InviteCodeRepository {
+	return &inviteCodeRepository{db: db}
+}
+
+func (r *inviteCodeRepository) CreateInviteCode(invite *models.InviteCode) error {
+	return r.db.Create(invite).Error
+}
+
+// GetInviteCodes returns the codes that have not been revoked, newest first.
+func (r *inviteCodeRepository) GetInviteCodes() ([]models.InviteCode, error) {
+	var invites []models.InviteCode
+	err := r.db.
+		Where("revoked_at IS NULL").
+		Order("created_at DESC").
+		Find(&invites).Error
+	return invites, err
+}
+
+func (r *inviteCodeRepository) RevokeInviteCode(id int) error {
+	result := r.db.Model(&models.InviteCode{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInviteCodeNotFound
+	}
+	return nil
+}
+
+// RedeemInviteCode uses up one use of an active code, or returns
+// ErrInviteCodeInvalid. The check and the increment are a single update, so
+// concurrent registrations cannot exceed the usage limit.
+func (r *inviteCodeRepository) RedeemInviteCode(code string) error {
+	result := r.db.Model(&models.InviteCode{}).
+		Where("code_hash = ? AND revoked_at IS NULL AND uses < max_uses AND (expires_at IS NULL OR expires_at > ?)",
+			utils.HashToken(code), time.Now()).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInviteCodeInvalid
+	}
+	return nil
+}
+
+// ReleaseInviteCode gives back a use taken by RedeemInviteCode when the
+// registration it was redeemed for failed.
+func (r *inviteCodeRepository) ReleaseInviteCode(code string) error {
+	return r.db.Model(&models.InviteCode{}).
+		Where("code_hash = ? AND uses > 0", utils.HashToken(code)).
+		Update("uses", gorm.Expr("uses - 1")).Error
+}
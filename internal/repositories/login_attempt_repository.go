@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttemptStore keeps count of failed logins. A failure recorded more
+// than resetAfter after the previous one starts counting from one again.
+type LoginAttemptStore interface {
+	GetLoginAttempt(subject string) (*models.LoginAttempt, error)
+	RecordLoginFailure(subject string, resetAfter time.Duration) (*models.LoginAttempt, error)
+	ResetLoginAttempts(subject string) error
+}
+
+// AccountSubject and IPSubject build the subjects failures are counted
+// against.
+func AccountSubject(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func IPSubject(ip string) string {
+	return "ip:" + ip
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginAttemptRepository returns a store backed by the database, shared by
+// every replica of the API.
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptStore {
+	return &loginAttemptRepository{db: db}
+}
+
+// GetLoginAttempt returns the failures recorded for the subject, or nil when
+// there are none.
+func (r *loginAttemptRepository) GetLoginAttempt(subject string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.db.Where("subject = ?", subject).First(&attempt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) RecordLoginFailure(subject string, resetAfter time.Duration) (*models.LoginAttempt, error) {
+	now := time.Now()
+
+	// A single upsert keeps concurrent failures from different replicas from
+	// overwriting each other's count.
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "failures"}, Value: gorm.Expr("CASE WHEN last_failed_at < ? THEN 1 ELSE failures + 1 END", now.Add(-resetAfter))},
+			{Column: clause.Column{Name: "last_failed_at"}, Value: now},
+		},
+	}).Create(&models.LoginAttempt{Subject: subject, Failures: 1, LastFailedAt: now}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetLoginAttempt(subject)
+}
+
+func (r *loginAttemptRepository) ResetLoginAttempts(subject string) error {
+	return r.db.Where("subject = ?", subject).Delete(&models.LoginAttempt{}).Error
+}
+
+// memoryLoginAttemptStore keeps failures in process memory. It is enough for
+// a single instance; replicas do not see each other's counts.
+type memoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+}
+
+const maxMemoryLoginAttempts = 10000
+
+func NewMemoryLoginAttemptStore() LoginAttemptStore {
+	return &memoryLoginAttemptStore{attempts: make(map[string]models.LoginAttempt)}
+}
+
+func (s *memoryLoginAttemptStore) GetLoginAttempt(subject string) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[subject]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (s *memoryLoginAttemptStore) RecordLoginFailure(subject string, resetAfter time.Duration) (*models.LoginAttempt, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.attempts) >= maxMemoryLoginAttempts {
+		for key, attempt := range s.attempts {
+			if now.Sub(attempt.LastFailedAt) > resetAfter {
+				delete(s.attempts, key)
+			}
+		}
+	}
+
+	attempt, ok := s.attempts[subject]
+	if !ok || now.Sub(attempt.LastFailedAt) > resetAfter {
+		attempt = models.LoginAttempt{Subject: subject}
+	}
+	attempt.Failures++
+	attempt.LastFailedAt = now
+	s.attempts[subject] = attempt
+
+	return &attempt, nil
+}
+
+func (s *memoryLoginAttemptStore) ResetLoginAttempts(subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, subject)
+	return nil
+}
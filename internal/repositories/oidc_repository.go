@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIdentityNotFound  = errors.New("identity not found")
+	ErrLoginStateInvalid = errors.New("login state invalid")
+)
+
+type OIDCRepository interface {
+	CreateLoginState(state *models.OIDCLoginState) error
+	ConsumeLoginState(hash, provider string) (*models.OIDCLoginState, error)
+	FindIdentity(provider, subject string) (*models.UserIdentity, error)
+	CreateIdentity(identity *models.UserIdentity) error
+}
+
+type oidcRepository struct {
+	db *gorm.DB
+}
+
+func NewOIDCRepository(db *gorm.DB) OIDCRepository {
+	return &oidcRepository{db: db}
+}
+
+// CreateLoginState stores a new login state, dropping expired ones on the way.
+func (r *oidcRepository) CreateLoginState(state *models.OIDCLoginState) error {
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		return err
+	}
+	return r.db.Create(state).Error
+}
+
+// ConsumeLoginState deletes a login state and returns it. Unknown, expired
+// and already used states, as well as states of another provider, all yield
+// ErrLoginStateInvalid.
+func (r *oidcRepository) ConsumeLoginState(hash, provider string) (*models.OIDCLoginState, error) {
+	var state models.OIDCLoginState
+	err := r.db.Where("state_hash = ? AND provider = ? AND expires_at > ?", hash, provider, time.Now()).
+		First(&state).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLoginStateInvalid
+		}
+		return nil, err
+	}
+
+	result := r.db.Where("state_hash = ?", hash).Delete(&models.OIDCLoginState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLoginStateInvalid
+	}
+	return &state, nil
+}
+
+func (r *oidcRepository) FindIdentity(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *oidcRepository) CreateIdentity(identity *models.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
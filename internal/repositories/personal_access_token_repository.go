@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+)
+
+// lastUsedResolution limits how often using a token writes to the database.
+const lastUsedResolution = time.Minute
+
+type PersonalAccessTokenRepository interface {
+	CreateToken(token *models.PersonalAccessToken) error
+	GetTokensByUserID(userID uint) ([]models.PersonalAccessToken, error)
+	GetTokenByID(id int, userID uint) (*models.PersonalAccessToken, error)
+	UpdateToken(token *models.PersonalAccessToken) error
+	RevokeToken(id int, userID uint) error
+	RevokeAllByUserID(userID uint) error
+	AuthenticatePersonalAccessToken(token string) (uint, []string, bool, error)
+}
+
+type personalAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{db: db}
+}
+
+func (r *personalAccessTokenRepository) CreateToken(token *models.PersonalAccessToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *personalAccessTokenRepository) GetTokensByUserID(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := r.db.
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *personalAccessTokenRepository) GetTokenByID(id int, userID uint) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := r.db.
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPersonalAccessTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *personalAccessTokenRepository) UpdateToken(token *models.PersonalAccessToken) error {
+	return r.db.Save(token).Error
+}
+
+func (r *personalAccessTokenRepository) RevokeToken(id int, userID uint) error {
+	result := r.db.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepository) RevokeAllByUserID(userID uint) error {
+	return r.db.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// AuthenticatePersonalAccessToken looks up an active token by its plaintext
+// value and returns the owner and granted scopes. Tokens of suspended or
+// deleted users, or of users who have to reset their password, are not
+// accepted. Last use is recorded with a resolution of a minute.
+func (r *personalAccessTokenRepository) AuthenticatePersonalAccessToken(plain string) (uint, []string, bool, error) {
+	var token models.PersonalAccessToken
+	now := time.Now()
+	err := r.db.
+		Joins("JOIN users ON users.id = personal_access_tokens.user_id AND users.suspended_at IS NULL AND users.deleted_at IS NULL AND users.password_reset_required = ?", false).
+		Where("personal_access_tokens.token_hash = ? AND personal_access_tokens.revoked_at IS NULL", utils.HashToken(plain)).
+		Where("personal_access_tokens.expires_at IS NULL OR personal_access_tokens.expires_at > ?", now).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil, false, nil
+		}
+		return 0, nil, false, err
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedResolution {
+		err := r.db.Model(&models.PersonalAccessToken{}).
+			Where("id = ?", token.ID).
+			UpdateColumn("last_used_at", now).Error
+		if err != nil {
+			return 0, nil, false, err
+		}
+	}
+
+	return token.UserID, token.ScopeList(), true, nil
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceRecoveryCodes(userID uint, hashes []string) error
+	ConsumeRecoveryCode(userID uint, hash string) (bool, error)
+	DeleteRecoveryCodes(userID uint) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+// ReplaceRecoveryCodes discards every recovery code of the user and stores the
+// given ones instead.
+func (r *recoveryCodeRepository) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.RecoveryCode, len(hashes))
+		for i, hash := range hashes {
+			codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// ConsumeRecoveryCode marks an unused code of the user as used and reports
+// whether one matched.
+func (r *recoveryCodeRepository) ConsumeRecoveryCode(userID uint, hash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *recoveryCodeRepository) DeleteRecoveryCodes(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
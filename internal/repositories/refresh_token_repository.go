@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+)
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(token *models.RefreshToken) error
+	FindByHash(hash string) (*models.RefreshToken, error)
+	MarkRotated(id uint) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeAllByUserID(userID uint) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *refreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRotated flags a refresh token as used. It reports false when the token
+// had already been rotated or revoked, which lets concurrent refresh requests
+// with the same token be detected as reuse.
+func (r *refreshTokenRepository) MarkRotated(id uint) (bool, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", id).
+		Update("rotated_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepository) RevokeAllByUserID(userID uint) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
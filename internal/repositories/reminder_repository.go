@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrReminderNotFound = errors.New("reminder not found")
+
+type ReminderRepository interface {
+	CreateReminder(reminder *models.Reminder) error
+	GetRemindersByTask(taskID, userID uint) ([]models.Reminder, error)
+	GetRemindersByUser(userID uint) ([]models.Reminder, error)
+	DeleteReminder(id, taskID, userID uint) error
+	SnoozeReminder(id, userID uint, until time.Time) (*models.Reminder, error)
+	DismissReminder(id, userID uint) (*models.Reminder, error)
+	RescheduleTaskReminders(task *models.Task, loc *time.Location) error
+	GetDueReminders(now time.Time, limit int) ([]models.Reminder, error)
+	ClaimReminder(id uint, token string, now, until time.Time) (bool, error)
+	MarkReminderSent(id uint, token string) error
+}
+
+type reminderRepository struct {
+	db *gorm.DB
+}
+
+func NewReminderRepository(db *gorm.DB) ReminderRepository {
+	return &reminderRepository{db: db}
+}
+
+func (r *reminderRepository) CreateReminder(reminder *models.Reminder) error {
+	return r.db.Omit("Task").Create(reminder).Error
+}
+
+// GetRemindersByTask returns the reminders of a task, soonest first.
+func (r *reminderRepository) GetRemindersByTask(taskID, userID uint) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.
+		Where("task_id = ? AND user_id = ?", taskID, userID).
+		Order("fire_at ASC").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// GetRemindersByUser returns the reminders of all tasks of the user, soonest
+// first.
+func (r *reminderRepository) GetRemindersByUser(userID uint) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.
+		Where("user_id = ?", userID).
+		Order("fire_at ASC").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+func (r *reminderRepository) DeleteReminder(id, taskID, userID uint) error {
+	result := r.db.
+		Where("id = ? AND task_id = ? AND user_id = ?", id, taskID, userID).
+		Delete(&models.Reminder{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+// SnoozeReminder sets the reminder to fire again at until, even if it was
+// already sent or dismissed.
+func (r *reminderRepository) SnoozeReminder(id, userID uint, until time.Time) (*models.Reminder, error) {
+	return r.updateReminder(id, userID, rearm(until))
+}
+
+func (r *reminderRepository) DismissReminder(id, userID uint) (*models.Reminder, error) {
+	return r.updateReminder(id, userID, map[string]interface{}{"dismissed_at": time.Now()})
+}
+
+func (r *reminderRepository) updateReminder(id, userID uint, updates map[string]interface{}) (*models.Reminder, error) {
+	result := r.db.Model(&models.Reminder{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrReminderNotFound
+	}
+
+	var reminder models.Reminder
+	err := r.db.First(&reminder, id).Error
+	return &reminder, err
+}
+
+// RescheduleTaskReminders moves the reminders relative to the due date of
+// the task after it changed, setting those that moved to fire again.
+// Reminders at an absolute time are kept as they are; relative ones are
+// removed when the task no longer has a due date.
+func (r *reminderRepository) RescheduleTaskReminders(task *models.Task, loc *time.Location) error {
+	var reminders []models.Reminder
+	if err := r.db.Where("task_id = ? AND offset_minutes IS NOT NULL", task.ID).Find(&reminders).Error; err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, reminder := range reminders {
+			previous := reminder.FireAt
+			if !reminder.Schedule(task, loc) {
+				if err := tx.Delete(&models.Reminder{}, reminder.ID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if reminder.FireAt.Equal(previous) {
+				continue
+			}
+			if err := tx.Model(&models.Reminder{}).Where("id = ?", reminder.ID).Updates(rearm(reminder.FireAt)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDueReminders returns up to limit reminders that should have fired by
+// now and are neither sent, dismissed nor claimed, for open tasks of active
+// accounts. The task and its owner are loaded for the email.
+func (r *reminderRepository) GetDueReminders(now time.Time, limit int) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.
+		Joins("JOIN tasks ON tasks.id = reminders.task_id AND tasks.status = ?", false).
+		Joins("JOIN users ON users.id = reminders.user_id AND users.deleted_at IS NULL AND users.suspended_at IS NULL").
+		Where("reminders.fire_at <= ? AND reminders.sent_at IS NULL AND reminders.dismissed_at IS NULL", now).
+		Where("reminders.claimed_until IS NULL OR reminders.claimed_until < ?", now).
+		Preload("Task.User").
+		Order("reminders.fire_at ASC").
+		Limit(limit).
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// ClaimReminder reserves a due reminder for sending until the given time.
+// The check and the claim are a single update, so only one replica wins it.
+func (r *reminderRepository) ClaimReminder(id uint, token string, now, until time.Time) (bool, error) {
+	result := r.db.Model(&models.Reminder{}).
+		Where("id = ? AND sent_at IS NULL AND dismissed_at IS NULL", id).
+		Where("claimed_until IS NULL OR claimed_until < ?", now).
+		Updates(map[string]interface{}{"claim_token": token, "claimed_until": until})
+	return result.RowsAffected == 1, result.Error
+}
+
+// MarkReminderSent records the reminder as sent, unless it was snoozed or
+// rescheduled while the claim was held.
+func (r *reminderRepository) MarkReminderSent(id uint, token string) error {
+	return r.db.Model(&models.Reminder{}).
+		Where("id = ? AND claim_token = ?", id, token).
+		Updates(map[string]interface{}{"sent_at": time.Now(), "claim_token": "", "claimed_until": nil}).Error
+}
+
+// rearm are the updates that make a reminder fire at the given time.
+func rearm(fireAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"fire_at":       fireAt,
+		"sent_at":       nil,
+		"dismissed_at":  nil,
+		"claim_token":   "",
+		"claimed_until": nil,
+	}
+}
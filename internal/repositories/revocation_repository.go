@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"gorm.io/gorm"
+)
+
+type RevocationRepository interface {
+	RevokeToken(jti string, userID uint, expiresAt time.Time) error
+	RevokeAllForUser(userID uint) error
+	IsTokenRevoked(jti string) (bool, error)
+	RevokedBefore(userID uint) (time.Time, error)
+}
+
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+func NewRevocationRepository(db *gorm.DB) RevocationRepository {
+	return &revocationRepository{db: db}
+}
+
+func (r *revocationRepository) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	// Entries are only useful until the token expires on its own, so this is a
+	// convenient place to keep the table small.
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	err := r.db.Create(&models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}).Error
+	if err != nil && !utils.IsDuplicateError(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *revocationRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("tokens_revoked_at", time.Now()).Error
+}
+
+func (r *revocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RevokedBefore returns the instant before which every token of the user is
+// considered revoked. It is the zero time when nothing was revoked, and the
+// current time when the user no longer exists, is suspended or deleted.
+func (r *revocationRepository) RevokedBefore(userID uint) (time.Time, error) {
+	var user models.User
+	err := r.db.Select("id", "tokens_revoked_at", "suspended_at", "deleted_at").First(&user, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Now(), nil
+		}
+		return time.Time{}, err
+	}
+	if user.IsSuspended() || user.DeletedAt != nil {
+		return time.Now(), nil
+	}
+	if user.TokensRevokedAt == nil {
+		return time.Time{}, nil
+	}
+	return *user.TokensRevokedAt, nil
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// SecurityEventFilter selects audit log entries. Zero fields match any event.
+type SecurityEventFilter struct {
+	UserID   uint
+	Type     models.SecurityEventType
+	Email    string
+	IP       string
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+// SecurityEventRepository stores the audit log. Events are only ever
+// appended: there are no methods to change or delete them. Purging a user
+// anonymizes their events (see PurgeDeletedUsers).
+type SecurityEventRepository interface {
+	CreateEvent(event *models.SecurityEvent) error
+	SearchEvents(filter SecurityEventFilter) ([]models.SecurityEvent, int64, error)
+	GetEventsByUserID(userID uint) ([]models.SecurityEvent, error)
+}
+
+type securityEventRepository struct {
+	db *gorm.DB
+}
+
+func NewSecurityEventRepository(db *gorm.DB) SecurityEventRepository {
+	return &securityEventRepository{db: db}
+}
+
+func (r *securityEventRepository) CreateEvent(event *models.SecurityEvent) error {
+	return r.db.Create(event).Error
+}
+
+// SearchEvents returns a page of events matching the filter, newest first,
+// along with the total number of matches.
+func (r *securityEventRepository) SearchEvents(filter SecurityEventFilter) ([]models.SecurityEvent, int64, error) {
+	query := r.db.Model(&models.SecurityEvent{})
+
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+
+	if filter.Email != "" {
+		query = query.Where("email = ?", filter.Email)
+	}
+
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []models.SecurityEvent
+	err := query.
+		Order("created_at DESC, id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&events).Error
+	return events, total, err
+}
+
+// GetEventsByUserID returns every event about the user, oldest first.
+func (r *securityEventRepository) GetEventsByUserID(userID uint) ([]models.SecurityEvent, error) {
+	var events []models.SecurityEvent
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC, id ASC").Find(&events).Error
+	return events, err
+}
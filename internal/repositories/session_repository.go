@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+type SessionRepository interface {
+	CreateSession(session *models.Session) error
+	GetSessionsByUserID(userID uint) ([]models.Session, error)
+	GetSession(id string, userID uint) (*models.Session, error)
+	TouchSession(id string, userID uint) (bool, error)
+	DeleteSession(id string, userID uint) error
+	DeleteUserSessions(userID uint, keepID string) ([]string, error)
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) CreateSession(session *models.Session) error {
+	session.LastSeenAt = time.Now()
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepository) GetSessionsByUserID(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *sessionRepository) GetSession(id string, userID uint) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// TouchSession records activity on a session and reports whether it still
+// exists for the given user.
+func (r *sessionRepository) TouchSession(id string, userID uint) (bool, error) {
+	var session models.Session
+	err := r.db.Select("id").Where("id = ? AND user_id = ?", id, userID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	err = r.db.Model(&models.Session{}).
+		Where("id = ?", id).
+		UpdateColumn("last_seen_at", time.Now()).Error
+	return err == nil, err
+}
+
+func (r *sessionRepository) DeleteSession(id string, userID uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// DeleteUserSessions removes every session of the user except keepID, which
+// may be empty, and returns the IDs of the deleted sessions.
+func (r *sessionRepository) DeleteUserSessions(userID uint, keepID string) ([]string, error) {
+	var ids []string
+	err := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ?", userID, keepID).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	if err := r.db.Where("id IN ?", ids).Delete(&models.Session{}).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// cachedSessionRepository remembers recently seen sessions so that
+// authenticated requests only reach the database, and refresh last-seen,
+// once per TTL. Deletions made through it are visible immediately on this
+// instance; other replicas notice them once their entries expire.
+type cachedSessionRepository struct {
+	SessionRepository
+	ttl time.Duration
+
+	mu   sync.Mutex
+	seen map[string]cachedSession
+}
+
+const maxCachedSessions = 10000
+
+type cachedSession struct {
+	userID    uint
+	expiresAt time.Time
+}
+
+func NewCachedSessionRepository(repo SessionRepository, ttl time.Duration) SessionRepository {
+	return &cachedSessionRepository{
+		SessionRepository: repo,
+		ttl:               ttl,
+		seen:              make(map[string]cachedSession),
+	}
+}
+
+func (r *cachedSessionRepository) TouchSession(id string, userID uint) (bool, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	entry, ok := r.seen[id]
+	r.mu.Unlock()
+	if ok && entry.userID == userID && now.Before(entry.expiresAt) {
+		return true, nil
+	}
+
+	found, err := r.SessionRepository.TouchSession(id, userID)
+	if err != nil || !found {
+		return found, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.seen) >= maxCachedSessions {
+		for key, cached := range r.seen {
+			if !now.Before(cached.expiresAt) {
+				delete(r.seen, key)
+			}
+		}
+	}
+	r.seen[id] = cachedSession{userID: userID, expiresAt: now.Add(r.ttl)}
+	return true, nil
+}
+
+func (r *cachedSessionRepository) DeleteSession(id string, userID uint) error {
+	r.forget(id)
+	return r.SessionRepository.DeleteSession(id, userID)
+}
+
+func (r *cachedSessionRepository) DeleteUserSessions(userID uint, keepID string) ([]string, error) {
+	ids, err := r.SessionRepository.DeleteUserSessions(userID, keepID)
+	r.forget(ids...)
+	return ids, err
+}
+
+func (r *cachedSessionRepository) forget(ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		delete(r.seen, id)
+	}
+}
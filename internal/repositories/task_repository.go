@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
@@ -17,10 +18,44 @@ var (
 	ErrInvalidFilter  = errors.New("invalid filter parameter")
 )
 
+// Values of TaskFilter.Due.
+const (
+	DueOverdue  = "overdue"
+	DueToday    = "today"
+	DueThisWeek = "week"
+	DueNone     = "none"
+)
+
+// TaskFilter narrows the tasks of a user. Empty fields match any task. Due
+// selects overdue tasks, tasks due today or this week, or tasks without a
+// due date; DueFrom and DueTo (inclusive, YYYY-MM-DD) a range of due dates.
+// Relative dates are computed in Location, with weeks starting on
+// WeekStart. ParentID selects the subtasks of a task, or top-level tasks
+// when 0; nil matches tasks at any level. Search matches text in the title
+// or description, and finds top-level tasks through their subtasks too.
+type TaskFilter struct {
+	ParentID   *uint
+	Search     string
+	CategoryID string
+	Status     string
+	Priority   string
+	Due        string
+	DueFrom    string
+	DueTo      string
+	Location   *time.Location
+	WeekStart  time.Weekday
+}
+
+const maxSearchLength = 100
+
+// likeEscaper escapes the wildcards of LIKE patterns, with an escape
+// character that needs no quoting in any supported database.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 type TaskRepository interface {
-	GetTasksByUserID(userID int, categoryID string, status string, priority string) ([]models.Task, error)
+	GetTasksByUserID(userID int, filter TaskFilter) ([]models.Task, error)
 	GetTaskByID(id int, userID int) (*models.Task, error)
-	GetTaskByTitleAndUserID(title string, userID int) (*models.Task, error)
+	GetTaskByTitleAndUserID(title string, userID int, parentID uint) (*models.Task, error)
 	CreateTask(task *models.Task) (*models.Task, error)
 	UpdateTask(task *models.Task) (*models.Task, error)
 	DeleteTask(id int, userID int) error
@@ -35,43 +70,158 @@ func NewTaskRepository(db *gorm.DB) TaskRepository {
 	return &taskRepository{db: db}
 }
 
-func (tr *taskRepository) GetTasksByUserID(userID int, categoryID string, status string, priority string) ([]models.Task, error) {
+func (tr *taskRepository) GetTasksByUserID(userID int, filter TaskFilter) ([]models.Task, error) {
 	query := tr.db.Model(&models.Task{}).
 		Where("user_id = ?", userID).
 		Preload("Category").
 		Preload("User")
 
-	if categoryID != "" {
-		if _, err := strconv.Atoi(categoryID); err != nil {
+	if filter.ParentID != nil {
+		query = query.Where("parent_id = ?", *filter.ParentID)
+	}
+
+	if filter.Search != "" {
+		if len(filter.Search) > maxSearchLength {
 			return nil, ErrInvalidFilter
 		}
-		query = query.Where("category_id = ?", categoryID)
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Search)) + "%"
+		match := "LOWER(title) LIKE ? ESCAPE '!' OR LOWER(description) LIKE ? ESCAPE '!'"
+		if filter.ParentID != nil && *filter.ParentID == 0 {
+			parents := tr.db.Model(&models.Task{}).
+				Select("parent_id").
+				Where("user_id = ? AND parent_id <> 0", userID).
+				Where(match, pattern, pattern)
+			query = query.Where("("+match+") OR id IN (?)", pattern, pattern, parents)
+		} else {
+			query = query.Where(match, pattern, pattern)
+		}
+	}
+
+	if filter.CategoryID != "" {
+		if _, err := strconv.Atoi(filter.CategoryID); err != nil {
+			return nil, ErrInvalidFilter
+		}
+		query = query.Where("category_id = ?", filter.CategoryID)
 	}
 
-	if status != "" {
-		parsedStatus, err := strconv.ParseBool(status)
+	if filter.Status != "" {
+		parsedStatus, err := strconv.ParseBool(filter.Status)
 		if err != nil {
 			return nil, ErrInvalidFilter
 		}
 		query = query.Where("status = ?", parsedStatus)
 	}
 
-	if priority != "" {
-		priority = strings.ToLower(priority)
+	if filter.Priority != "" {
+		priority := strings.ToLower(filter.Priority)
 		if !models.IsValidPriority(models.Priority(priority)) {
 			return nil, ErrInvalidFilter
 		}
 		query = query.Where("priority = ?", priority)
 	}
 
+	query, err := filterByDueDate(query, filter)
+	if err != nil {
+		return nil, err
+	}
+
 	var tasks []models.Task
 	if err := query.Find(&tasks).Error; err != nil {
 		return nil, fmt.Errorf("error fetching tasks: %w", err)
 	}
 
+	if err := tr.loadSubtaskProgress(tasks); err != nil {
+		return nil, fmt.Errorf("error fetching subtasks: %w", err)
+	}
+
 	return tasks, nil
 }
 
+// loadSubtaskProgress counts the subtasks of the tasks, and how many of
+// them are done.
+func (tr *taskRepository) loadSubtaskProgress(tasks []models.Task) error {
+	ids := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		if task.ParentID == 0 {
+			ids = append(ids, task.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var progress []struct {
+		ParentID uint
+		Done     int
+		Total    int
+	}
+	err := tr.db.Model(&models.Task{}).
+		Select("parent_id, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS done, COUNT(*) AS total", true).
+		Where("parent_id IN ?", ids).
+		Group("parent_id").
+		Scan(&progress).Error
+	if err != nil {
+		return err
+	}
+
+	for _, p := range progress {
+		for i := range tasks {
+			if tasks[i].ID == p.ParentID {
+				tasks[i].SubtasksDone = p.Done
+				tasks[i].SubtasksTotal = p.Total
+			}
+		}
+	}
+	return nil
+}
+
+// filterByDueDate applies the date filters. Dates are stored as YYYY-MM-DD
+// and times as HH:MM, which compare in calendar order as strings.
+func filterByDueDate(query *gorm.DB, filter TaskFilter) (*gorm.DB, error) {
+	loc := filter.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	today := now.Format(models.DateLayout)
+
+	switch filter.Due {
+	case "":
+	case DueOverdue:
+		query = query.Where("status = ?", false).
+			Where("due_date < ? OR (due_date = ? AND due_time < ?)", today, today, now.Format(models.TimeLayout))
+	case DueToday:
+		query = query.Where("due_date = ?", today)
+	case DueThisWeek:
+		offset := (int(now.Weekday()) - int(filter.WeekStart) + 7) % 7
+		start := now.AddDate(0, 0, -offset)
+		query = query.Where("due_date BETWEEN ? AND ?",
+			start.Format(models.DateLayout), start.AddDate(0, 0, 6).Format(models.DateLayout))
+	case DueNone:
+		query = query.Where("due_date IS NULL")
+	default:
+		return nil, ErrInvalidFilter
+	}
+
+	for _, bound := range []struct {
+		value string
+		cond  string
+	}{
+		{filter.DueFrom, "due_date >= ?"},
+		{filter.DueTo, "due_date <= ?"},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		if _, err := time.Parse(models.DateLayout, bound.value); err != nil {
+			return nil, ErrInvalidFilter
+		}
+		query = query.Where(bound.cond, bound.value)
+	}
+
+	return query, nil
+}
+
 func (tr *taskRepository) GetTaskByID(id int, userID int) (*models.Task, error) {
 	var task models.Task
 	err := tr.db.
@@ -83,14 +233,21 @@ func (tr *taskRepository) GetTaskByID(id int, userID int) (*models.Task, error)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, ErrTaskNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	return &task, err
+	tasks := []models.Task{task}
+	if err := tr.loadSubtaskProgress(tasks); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
 }
 
-func (tr *taskRepository) GetTaskByTitleAndUserID(title string, userID int) (*models.Task, error) {
+func (tr *taskRepository) GetTaskByTitleAndUserID(title string, userID int, parentID uint) (*models.Task, error) {
 	var task models.Task
 	err := tr.db.
-		Where("user_id = ? AND title = ?", userID, title).
+		Where("user_id = ? AND parent_id = ? AND title = ?", userID, parentID, title).
 		First(&task).Error
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -124,20 +281,28 @@ func (tr *taskRepository) UpdateTask(task *models.Task) (*models.Task, error) {
 	return tr.GetTaskByID(int(task.ID), int(task.UserID))
 }
 
+// DeleteTask deletes a task along with its subtasks and their reminders.
 func (tr *taskRepository) DeleteTask(id int, userID int) error {
-	result := tr.db.
-		Where("id = ? AND user_id = ?", id, userID).
-		Delete(&models.Task{})
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("id = ? AND user_id = ?", id, userID).
+			Delete(&models.Task{})
 
-	if result.Error != nil {
-		return result.Error
-	}
+		if result.Error != nil {
+			return result.Error
+		}
 
-	if result.RowsAffected == 0 {
-		return ErrTaskNotFound
-	}
+		if result.RowsAffected == 0 {
+			return ErrTaskNotFound
+		}
 
-	return nil
+		subtasks := tx.Model(&models.Task{}).Select("id").Where("parent_id = ?", id)
+		if err := tx.Where("task_id = ? OR task_id IN (?)", id, subtasks).Delete(&models.Reminder{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("parent_id = ?", id).Delete(&models.Task{}).Error
+	})
 }
 
 func (tr *taskRepository) ToggleTaskStatus(id int, userID int) (*models.Task, error) {
@@ -146,6 +311,22 @@ func (tr *taskRepository) ToggleTaskStatus(id int, userID int) (*models.Task, er
 		return nil, err
 	}
 
-	task.Status = !task.Status
+	// Completing a recurring task moves it to its next occurrence, in the
+	// time zone of its owner, and leaves it open with its subtasks reopened
+	// for the new occurrence. Completing any other task completes its
+	// subtasks; reopening it leaves them as they are.
+	advanced := !task.Status && task.AdvanceRecurrence(time.Now().In(task.User.Location()))
+	if !advanced {
+		task.Status = !task.Status
+	}
+
+	if task.ParentID == 0 && (advanced || task.Status) {
+		err := tr.db.Model(&models.Task{}).
+			Where("parent_id = ?", task.ID).
+			Update("status", task.Status).Error
+		if err != nil {
+			return nil, fmt.Errorf("error updating subtasks: %w", err)
+		}
+	}
 	return tr.UpdateTask(task)
 }
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository interface {
+	CreateUserToken(token *models.UserToken) error
+	ConsumeUserToken(hash string, purpose models.TokenPurpose) (*models.UserToken, error)
+	InvalidateUserTokens(userID uint, purpose models.TokenPurpose) error
+	LastIssuedAt(userID uint, purpose models.TokenPurpose) (time.Time, error)
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) CreateUserToken(token *models.UserToken) error {
+	return r.db.Create(token).Error
+}
+
+// ConsumeUserToken marks a token as used and returns it. Unknown, expired and
+// already used tokens all yield ErrTokenNotFound.
+func (r *userTokenRepository) ConsumeUserToken(hash string, purpose models.TokenPurpose) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hash, purpose, time.Now()).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	result := r.db.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTokenNotFound
+	}
+
+	token.UsedAt = &now
+	return &token, nil
+}
+
+func (r *userTokenRepository) InvalidateUserTokens(userID uint, purpose models.TokenPurpose) error {
+	return r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// LastIssuedAt returns when the most recent token of the given purpose was
+// created for the user, or the zero time if there is none.
+func (r *userTokenRepository) LastIssuedAt(userID uint, purpose models.TokenPurpose) (time.Time, error) {
+	var token models.UserToken
+	err := r.db.
+		Where("user_id = ? AND purpose = ?", userID, purpose).
+		Order("created_at DESC").
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return token.CreatedAt, nil
+}
@@ -1,28 +1,39 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
-	"os"
 	"strings"
-
 	"time"
 
 	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-type CustomClaims struct {
-	Email  string `json:"email"`
-	UserID int    `json:"userId"`
-	jwt.RegisteredClaims
+// SessionStore reports whether the session a token belongs to still exists,
+// recording activity on it at the same time.
+type SessionStore interface {
+	TouchSession(id string, userID uint) (bool, error)
+}
+
+// PersonalAccessTokenStore resolves a personal access token to its owner and
+// granted scopes. ok is false for unknown, expired or revoked tokens.
+type PersonalAccessTokenStore interface {
+	AuthenticatePersonalAccessToken(token string) (userID uint, scopes []string, ok bool, err error)
+}
+
+type AuthConfig struct {
+	Keys                 *utils.JWTKeySet
+	Revocations          RevocationStore
+	Sessions             SessionStore
+	PersonalAccessTokens PersonalAccessTokenStore
 }
 
 // AuthMiddleware godoc
 // @Security ApiKeyAuth
-// @Description JWT Authentication Middleware with refresh detection
-// @Param Authorization header string true "JWT Token" default(Bearer <token>)
-func AuthMiddleware() gin.HandlerFunc {
+// @Description Authentication middleware accepting JWT access tokens and personal access tokens
+// @Param Authorization header string true "JWT or personal access token" default(Bearer <token>)
+func AuthMiddleware(config AuthConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
@@ -33,32 +44,89 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 
-		secret := os.Getenv("JWT_SECRET")
-		claims := &CustomClaims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
+		if strings.HasPrefix(tokenString, utils.PersonalAccessTokenPrefix) {
+			authenticatePersonalAccessToken(c, config.PersonalAccessTokens, tokenString)
+			return
+		}
 
-		if err != nil || !token.Valid {
+		claims, err := config.Keys.ParseAccessToken(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		if claims.ExpiresAt != nil {
-			remaining := time.Until(claims.ExpiresAt.Time)
-			if remaining < 5*time.Minute {
-				newTokenString, err := utils.GenerateJWT(claims.Email, claims.UserID)
-				if err == nil {
-					c.Header("X-Refresh-Token", newTokenString)
-				}
-			}
+		revoked, err := isRevoked(config.Revocations, claims)
+		if err != nil {
+			log.Printf("Could not check token revocation for user %d: %v", claims.UserID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		active, err := config.Sessions.TouchSession(claims.SessionID, uint(claims.UserID))
+		if err != nil {
+			log.Printf("Could not check session %s for user %d: %v", claims.SessionID, claims.UserID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+			c.Abort()
+			return
 		}
 
 		c.Set("userEmail", claims.Email)
 		c.Set("userID", claims.UserID)
+		c.Set("userRole", claims.Role)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("tokenID", claims.ID)
+		c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
 
 		c.Next()
 	}
 }
+
+func authenticatePersonalAccessToken(c *gin.Context, store PersonalAccessTokenStore, token string) {
+	userID, scopes, ok, err := store.AuthenticatePersonalAccessToken(token)
+	if err != nil {
+		log.Printf("Could not check personal access token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		c.Abort()
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", int(userID))
+	c.Set("tokenScopes", scopes)
+
+	c.Next()
+}
+
+func isRevoked(revocations RevocationStore, claims *utils.AccessClaims) (bool, error) {
+	revoked, err := revocations.IsTokenRevoked(claims.ID)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	cutoff, err := revocations.RevokedBefore(uint(claims.UserID))
+	if err != nil || cutoff.IsZero() {
+		return false, err
+	}
+
+	// iat only has a resolution of a second. Tokens issued in the same second
+	// as the cutoff are let through here; revoking everything also ends the
+	// sessions, so tokens from before the cutoff are still rejected by the
+	// session check while fresh logins keep working.
+	return claims.IssuedAt == nil || claims.IssuedAt.Before(cutoff.Truncate(time.Second)), nil
+}
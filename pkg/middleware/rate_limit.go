@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate allows bursts of up to Requests requests, refilled evenly over Period.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+func PerMinute(requests int) Rate {
+	return Rate{Requests: requests, Period: time.Minute}
+}
+
+func PerHour(requests int) Rate {
+	return Rate{Requests: requests, Period: time.Hour}
+}
+
+// RateLimits are the limits applied by the routes.
+type RateLimits struct {
+	// Auth limits each client IP on the authentication endpoints.
+	Auth Rate
+	// AuthEmail limits each client IP on every endpoint that emails an
+	// address given in the request, counted separately per endpoint.
+	AuthEmail Rate
+	// API limits each user on the authenticated API.
+	API Rate
+	// Account limits each user on data exports and email changes.
+	Account Rate
+}
+
+// LoadRateLimits reads the limits from RATE_LIMIT_AUTH_PER_MINUTE,
+// RATE_LIMIT_AUTH_EMAIL_PER_HOUR, RATE_LIMIT_API_PER_MINUTE and
+// RATE_LIMIT_ACCOUNT_PER_HOUR.
+func LoadRateLimits() RateLimits {
+	return RateLimits{
+		Auth:      PerMinute(envRequests("RATE_LIMIT_AUTH_PER_MINUTE", 20)),
+		AuthEmail: PerHour(envRequests("RATE_LIMIT_AUTH_EMAIL_PER_HOUR", 5)),
+		API:       PerMinute(envRequests("RATE_LIMIT_API_PER_MINUTE", 300)),
+		Account:   PerHour(envRequests("RATE_LIMIT_ACCOUNT_PER_HOUR", 5)),
+	}
+}
+
+func envRequests(name string, fallback int) int {
+	requests, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || requests <= 0 {
+		return fallback
+	}
+	return requests
+}
+
+// RateLimitResult describes the quota left after a request was counted.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // until the next request is allowed, when denied
+	Reset      time.Duration // until the quota is fully restored
+}
+
+// RateLimitStore counts requests against a rate. Implementations must be safe
+// for concurrent use; a store shared by several replicas makes the limits
+// apply to the whole deployment rather than per instance.
+type RateLimitStore interface {
+	Take(key string, rate Rate) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc identifies who a request is counted against.
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// KeyByIP counts requests per client IP. X-Forwarded-For is only taken into
+// account from the trusted proxies configured on the router.
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser counts requests per authenticated user, falling back to the
+// client IP. It has to run after AuthMiddleware.
+func KeyByUser(c *gin.Context) string {
+	if userID, ok := c.Get("userID"); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return KeyByIP(c)
+}
+
+// RateLimit rejects requests over the rate with 429 and reports the quota in
+// the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers. name separates the counters of limiters sharing a
+// store. If the store fails, requests are let through.
+func RateLimit(store RateLimitStore, name string, rate Rate, key RateLimitKeyFunc) gin.HandlerFunc {
+	policy := fmt.Sprintf("%d;w=%d", rate.Requests, int(rate.Period.Seconds()))
+
+	return func(c *gin.Context) {
+		result, err := store.Take(name+":"+key(c), rate)
+		if err != nil {
+			log.Printf("Rate limiter %s failed: %v", name, err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(rate.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		c.Header("RateLimit-Policy", policy)
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests. Please try again later"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// memoryRateLimitStore implements token buckets in process memory. Limits are
+// per instance.
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+const maxRateLimitBuckets = 100000
+
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (s *memoryRateLimitStore) Take(key string, rate Rate) (RateLimitResult, error) {
+	now := time.Now()
+	capacity := float64(rate.Requests)
+	perToken := rate.Period / time.Duration(rate.Requests)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		s.prune(now)
+		bucket = &tokenBucket{tokens: capacity, updated: now}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updated)
+	bucket.tokens = math.Min(capacity, bucket.tokens+elapsed.Seconds()/perToken.Seconds())
+	bucket.updated = now
+
+	result := RateLimitResult{Allowed: bucket.tokens >= 1}
+	if result.Allowed {
+		bucket.tokens--
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) * float64(perToken))
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = time.Duration((capacity - bucket.tokens) * float64(perToken))
+	bucket.full = now.Add(result.Reset)
+
+	return result, nil
+}
+
+// prune drops buckets that have refilled completely, which behave the same
+// as missing ones, once the store grows past its soft limit. The caller must
+// hold the lock.
+func (s *memoryRateLimitStore) prune(now time.Time) {
+	if len(s.buckets) < maxRateLimitBuckets {
+		return
+	}
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
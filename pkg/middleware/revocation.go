@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore decides whether an access token may still be used. Tokens
+// can be revoked one by one through their jti claim, or all at once for a
+// user, in which case every token issued up to that instant is rejected.
+type RevocationStore interface {
+	RevokeToken(jti string, userID uint, expiresAt time.Time) error
+	RevokeAllForUser(userID uint) error
+	IsTokenRevoked(jti string) (bool, error)
+	RevokedBefore(userID uint) (time.Time, error)
+}
+
+type cachedValue[T any] struct {
+	value     T
+	expiresAt time.Time
+}
+
+// revocationCache keeps lookups from the backing store in memory for a short
+// time, so the middleware does not hit the database on every request.
+// Revocations done through the cache are visible immediately on this
+// instance; other replicas pick them up once their entries expire.
+type revocationCache struct {
+	store RevocationStore
+	ttl   time.Duration
+
+	mu     sync.Mutex
+	tokens map[string]cachedValue[bool]
+	users  map[uint]cachedValue[time.Time]
+}
+
+const maxCachedRevocations = 10000
+
+func NewRevocationCache(store RevocationStore, ttl time.Duration) RevocationStore {
+	return &revocationCache{
+		store:  store,
+		ttl:    ttl,
+		tokens: make(map[string]cachedValue[bool]),
+		users:  make(map[uint]cachedValue[time.Time]),
+	}
+}
+
+func (rc *revocationCache) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	if err := rc.store.RevokeToken(jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.tokens[jti] = cachedValue[bool]{value: true, expiresAt: expiresAt}
+	return nil
+}
+
+func (rc *revocationCache) RevokeAllForUser(userID uint) error {
+	if err := rc.store.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.users, userID)
+	return nil
+}
+
+func (rc *revocationCache) IsTokenRevoked(jti string) (bool, error) {
+	now := time.Now()
+
+	rc.mu.Lock()
+	entry, ok := rc.tokens[jti]
+	rc.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	revoked, err := rc.store.IsTokenRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.prune(now)
+	rc.tokens[jti] = cachedValue[bool]{value: revoked, expiresAt: now.Add(rc.ttl)}
+	return revoked, nil
+}
+
+func (rc *revocationCache) RevokedBefore(userID uint) (time.Time, error) {
+	now := time.Now()
+
+	rc.mu.Lock()
+	entry, ok := rc.users[userID]
+	rc.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	cutoff, err := rc.store.RevokedBefore(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.prune(now)
+	rc.users[userID] = cachedValue[time.Time]{value: cutoff, expiresAt: now.Add(rc.ttl)}
+	return cutoff, nil
+}
+
+// prune drops expired entries once the cache grows past its soft limit. The
+// caller must hold the lock.
+func (rc *revocationCache) prune(now time.Time) {
+	if len(rc.tokens)+len(rc.users) < maxCachedRevocations {
+		return
+	}
+	for jti, entry := range rc.tokens {
+		if !now.Before(entry.expiresAt) {
+			delete(rc.tokens, jti)
+		}
+	}
+	for userID, entry := range rc.users {
+		if !now.Before(entry.expiresAt) {
+			delete(rc.users, userID)
+		}
+	}
+}
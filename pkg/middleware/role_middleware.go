@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets through requests whose access token carries one of
+// the given roles. Personal access tokens carry no role and are rejected.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("userRole")
+		for _, allowed := range roles {
+			if role != "" && role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/A4GOD-AMHG/sylcot-go-gin-backend/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireProvisioningToken only lets through requests carrying the bearer
+// token configured for the identity provider that provisions users through
+// SCIM. Every request is rejected when no token is configured.
+func RequireProvisioningToken(token string) gin.HandlerFunc {
+	expected := utils.HashToken(token)
+	return func(c *gin.Context) {
+		presented, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || !ok ||
+			subtle.ConstantTimeCompare([]byte(utils.HashToken(presented)), []byte(expected)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+				"status":  "401",
+				"detail":  "Invalid or missing provisioning token",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
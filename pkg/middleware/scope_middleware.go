@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope only lets personal access tokens through when they were
+// granted the scope. Requests authenticated with a login session have access
+// to everything the user can do and are not restricted.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, isPersonalToken := c.Get("tokenScopes")
+		if !isPersonalToken {
+			c.Next()
+			return
+		}
+
+		for _, granted := range value.([]string) {
+			if granted == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Token is missing the required scope " + scope})
+		c.Abort()
+	}
+}
+
+// RequireSessionAuth rejects requests made with a personal access token, for
+// account management endpoints that need an interactive login.
+func RequireSessionAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isPersonalToken := c.Get("tokenScopes"); isPersonalToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with a personal access token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetAccountDeletionGracePeriod returns how long a deleted account is kept,
+// and can still be restored by logging in, before it is purged.
+func GetAccountDeletionGracePeriod() time.Duration {
+	daysStr := os.Getenv("ACCOUNT_DELETION_GRACE_DAYS")
+	if daysStr == "" {
+		return time.Hour * 24 * 30
+	}
+	days, err := strconv.Atoi(daysStr)
+	if err != nil {
+		return time.Hour * 24 * 30
+	}
+	return time.Hour * 24 * time.Duration(days)
+}
+
+// GetReauthWindow returns for how long after logging in users without a
+// password may confirm sensitive changes without one.
+func GetReauthWindow() time.Duration {
+	minutesStr := os.Getenv("REAUTH_WINDOW_MINUTES")
+	if minutesStr == "" {
+		return time.Minute * 10
+	}
+	minutes, err := strconv.Atoi(minutesStr)
+	if err != nil {
+		return time.Minute * 10
+	}
+	return time.Minute * time.Duration(minutes)
+}
@@ -1,32 +1,202 @@
 package utils
 
 import (
+	"crypto/tls"
+	"fmt"
+	"net"
 	"net/smtp"
 	"os"
+	"time"
 )
 
-func SendVerificationEmail(email, link string) error {
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
-	from := os.Getenv("SMTP_USER")
-	password := os.Getenv("SMTP_PASSWORD")
-	subject := "Email Verification"
-	body := "Click the following link to verify your email: " + link
+const DefaultLocale = "en"
 
-	auth := smtp.PlainAuth("", from, password, smtpHost)
-	msg := []byte("Subject: " + subject + "\r\n\r\n" + body)
-	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{email}, msg)
+// EmailTimeout bounds sending one email, from connecting to the SMTP server
+// to the end of the conversation.
+const EmailTimeout = 30 * time.Second
+
+// SupportedLocales lists the languages emails can be sent in.
+var SupportedLocales = []string{"en", "es"}
+
+func IsSupportedLocale(locale string) bool {
+	for _, supported := range SupportedLocales {
+		if locale == supported {
+			return true
+		}
+	}
+	return false
+}
+
+type emailTemplate struct {
+	subject string
+	body    string
+}
+
+// emailTemplates holds every email per locale. Bodies are format strings
+// taking the arguments documented on the matching Send function.
+var emailTemplates = map[string]map[string]emailTemplate{
+	"verification": {
+		"en": {"Email Verification", "Click the following link to verify your email: %s"},
+		"es": {"Verificación de correo electrónico", "Haz clic en el siguiente enlace para verificar tu correo electrónico: %s"},
+	},
+	"reset_password": {
+		"en": {"Password reset request", "Click the following link to reset your password: %s"},
+		"es": {"Solicitud de restablecimiento de contraseña", "Haz clic en el siguiente enlace para restablecer tu contraseña: %s"},
+	},
+	"account_locked": {
+		"en": {"Your account has been temporarily locked",
+			"We detected too many failed login attempts on your account, so logging in has been blocked until %s.\r\n\r\n" +
+				"If this was not you, we recommend resetting your password."},
+		"es": {"Tu cuenta ha sido bloqueada temporalmente",
+			"Detectamos demasiados intentos fallidos de inicio de sesión en tu cuenta, por lo que el acceso está bloqueado hasta %s.\r\n\r\n" +
+				"Si no fuiste tú, te recomendamos restablecer tu contraseña."},
+	},
+	"email_change_confirmation": {
+		"en": {"Confirm your new email address", "Click the following link to use this address for your Sylcot account: %s"},
+		"es": {"Confirma tu nueva dirección de correo", "Haz clic en el siguiente enlace para usar esta dirección en tu cuenta de Sylcot: %s"},
+	},
+	"email_change_notice": {
+		"en": {"Your email address is being changed",
+			"A change of the email address of your Sylcot account to %s was requested. " +
+				"It takes effect once confirmed from the new address.\r\n\r\n" +
+				"If this was not you, reset your password and log out of all sessions."},
+		"es": {"Se está cambiando tu dirección de correo",
+			"Se solicitó cambiar la dirección de correo de tu cuenta de Sylcot a %s. " +
+				"El cambio se aplica una vez confirmado desde la nueva dirección.\r\n\r\n" +
+				"Si no fuiste tú, restablece tu contraseña y cierra todas las sesiones."},
+	},
+	"magic_link": {
+		"en": {"Your Sylcot sign-in link",
+			"Click the following link to log in to Sylcot. It can be used once and expires in 15 minutes: %s\r\n\r\n" +
+				"If you did not request it, you can ignore this email."},
+		"es": {"Tu enlace de inicio de sesión en Sylcot",
+			"Haz clic en el siguiente enlace para iniciar sesión en Sylcot. Solo puede usarse una vez y caduca en 15 minutos: %s\r\n\r\n" +
+				"Si no lo solicitaste, puedes ignorar este correo."},
+	},
+	"account_deletion_scheduled": {
+		"en": {"Your account will be deleted",
+			"Your Sylcot account and all of its data will be permanently deleted on %s.\r\n\r\n" +
+				"Changed your mind? Log in before then to keep your account."},
+		"es": {"Tu cuenta será eliminada",
+			"Tu cuenta de Sylcot y todos sus datos se eliminarán de forma permanente el %s.\r\n\r\n" +
+				"¿Cambiaste de opinión? Inicia sesión antes de esa fecha para conservar tu cuenta."},
+	},
+	"task_reminder": {
+		"en": {"Task reminder", "This is a reminder of your task \"%s\", due %s."},
+		"es": {"Recordatorio de tarea", "Este es un recordatorio de tu tarea \"%s\", que vence el %s."},
+	},
+	"task_reminder_undated": {
+		"en": {"Task reminder", "This is a reminder of your task \"%s\"."},
+		"es": {"Recordatorio de tarea", "Este es un recordatorio de tu tarea \"%s\"."},
+	},
+}
+
+// FormatEmailTime formats a time for an email, in the recipient's time zone.
+func FormatEmailTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02 15:04 MST")
 }
 
-func SendResetPasswordEmail(email, link string) error {
+func sendTemplate(to, locale, name string, args ...interface{}) error {
+	templates := emailTemplates[name]
+	template, ok := templates[locale]
+	if !ok {
+		template = templates[DefaultLocale]
+	}
+	return sendEmail(to, template.subject, fmt.Sprintf(template.body, args...))
+}
+
+func sendEmail(to, subject, body string) error {
 	smtpHost := os.Getenv("SMTP_HOST")
 	smtpPort := os.Getenv("SMTP_PORT")
 	from := os.Getenv("SMTP_USER")
 	password := os.Getenv("SMTP_PASSWORD")
-	subject := "Solicitud de restablecimiento de contraseña"
-	body := "Haz clic en el siguiente enlace para restablecer tu contraseña: " + link
 
 	auth := smtp.PlainAuth("", from, password, smtpHost)
 	msg := []byte("Subject: " + subject + "\r\n\r\n" + body)
-	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{email}, msg)
+
+	// Like smtp.SendMail, but a server that stops answering cannot hold the
+	// request or the job sending the email forever.
+	conn, err := net.DialTimeout("tcp", smtpHost+":"+smtpPort, EmailTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(EmailTimeout)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, smtpHost)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: smtpHost}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func SendVerificationEmail(email, locale, link string) error {
+	return sendTemplate(email, locale, "verification", link)
+}
+
+func SendResetPasswordEmail(email, locale, link string) error {
+	return sendTemplate(email, locale, "reset_password", link)
+}
+
+// SendAccountLockedEmail tells the user their account is locked until the
+// given time, already formatted with FormatEmailTime.
+func SendAccountLockedEmail(email, locale, until string) error {
+	return sendTemplate(email, locale, "account_locked", until)
+}
+
+func SendEmailChangeConfirmation(email, locale, link string) error {
+	return sendTemplate(email, locale, "email_change_confirmation", link)
+}
+
+func SendEmailChangeNotice(email, locale, newEmail string) error {
+	return sendTemplate(email, locale, "email_change_notice", newEmail)
+}
+
+func SendMagicLinkEmail(email, locale, link string) error {
+	return sendTemplate(email, locale, "magic_link", link)
+}
+
+// SendAccountDeletionScheduledEmail tells the user when their account will be
+// purged, already formatted with FormatEmailTime.
+func SendAccountDeletionScheduledEmail(email, locale, purgeAt string) error {
+	return sendTemplate(email, locale, "account_deletion_scheduled", purgeAt)
+}
+
+// SendTaskReminderEmail reminds the user of a task. due is when the task is
+// due, already formatted, or empty for tasks without a due date.
+func SendTaskReminderEmail(email, locale, title, due string) error {
+	if due == "" {
+		return sendTemplate(email, locale, "task_reminder_undated", title)
+	}
+	return sendTemplate(email, locale, "task_reminder", title, due)
 }
@@ -1,34 +1,200 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
-func getJWTExpiration() time.Duration {
+// Values of the typ claim, which keeps tokens issued for one purpose from
+// being accepted for another.
+const (
+	TokenTypeAccess     = "access"
+	TokenTypeMFAPending = "mfa_pending"
+)
+
+const mfaTokenExpiration = 5 * time.Minute
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// GetJWTExpiration returns the lifetime of access tokens. They are meant to be
+// short-lived; clients keep their session alive with a refresh token.
+func GetJWTExpiration() time.Duration {
 	minutesStr := os.Getenv("JWT_EXPIRATION_MINUTES")
 	if minutesStr == "" {
-		return time.Minute * 4320
+		return time.Minute * 15
 	}
 	minutes, err := strconv.Atoi(minutesStr)
 	if err != nil {
-		return time.Minute * 4320
+		return time.Minute * 15
 	}
 	return time.Minute * time.Duration(minutes)
 }
 
-func GenerateJWT(email string, id int) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	expiration := getJWTExpiration()
-	claims := jwt.MapClaims{
-		"email":  email,
-		"userId": id,
-		"iat":    time.Now().Unix(),
-		"exp":    time.Now().Add(expiration).Unix(),
+// GetRefreshTokenExpiration returns the lifetime of refresh tokens.
+func GetRefreshTokenExpiration() time.Duration {
+	hoursStr := os.Getenv("REFRESH_TOKEN_EXPIRATION_HOURS")
+	if hoursStr == "" {
+		return time.Hour * 720
+	}
+	hours, err := strconv.Atoi(hoursStr)
+	if err != nil {
+		return time.Hour * 720
+	}
+	return time.Hour * time.Duration(hours)
+}
+
+// GetJWTIssuer returns the iss claim of issued tokens: JWT_ISSUER, or the
+// public URL of the API. The discovery document is served under it.
+func GetJWTIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return strings.TrimSuffix(issuer, "/")
+	}
+	if apiURL := os.Getenv("API_URL"); apiURL != "" {
+		return strings.TrimSuffix(apiURL, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// GetJWTAudience returns the aud claim of issued tokens, which is also the
+// only audience accepted.
+func GetJWTAudience() string {
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		return audience
+	}
+	return "sylcot-api"
+}
+
+// mfaAudience is the aud claim of mfa_pending tokens. It differs from the
+// audience of access tokens, so verifiers that only check the signature, iss
+// and aud still reject them.
+func mfaAudience() string {
+	return GetJWTAudience() + "/mfa"
+}
+
+// GetJWTClockSkew returns how far exp, nbf and iat may be off when validating
+// tokens, to allow for clocks of other issuing replicas drifting.
+func GetJWTClockSkew() time.Duration {
+	secondsStr := os.Getenv("JWT_CLOCK_SKEW_SECONDS")
+	if secondsStr == "" {
+		return time.Second * 30
+	}
+	seconds, err := strconv.Atoi(secondsStr)
+	if err != nil || seconds < 0 {
+		return time.Second * 30
+	}
+	return time.Second * time.Duration(seconds)
+}
+
+// AccessClaims are the claims of an access token. sub holds the user ID as a
+// string; userId is kept for existing clients.
+type AccessClaims struct {
+	Email     string `json:"email"`
+	UserID    int    `json:"userId"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	TokenType string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// MFAClaims are the claims of the token proving the password of a two-factor
+// login was correct.
+type MFAClaims struct {
+	UserID     int    `json:"userId"`
+	DeviceName string `json:"dev"`
+	TokenType  string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// registeredClaims fills in the standard claims of a token issued now.
+func registeredClaims(subject int, audience string, expiration time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    GetJWTIssuer(),
+		Subject:   strconv.Itoa(subject),
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        uuid.NewString(),
+	}
+}
+
+// GenerateJWT issues an access token for a session.
+func (ks *JWTKeySet) GenerateJWT(email string, id int, sessionID string, role string) (string, error) {
+	return ks.Sign(&AccessClaims{
+		Email:            email,
+		UserID:           id,
+		Role:             role,
+		SessionID:        sessionID,
+		TokenType:        TokenTypeAccess,
+		RegisteredClaims: registeredClaims(id, GetJWTAudience(), GetJWTExpiration()),
+	})
+}
+
+// ParseAccessToken validates a token from GenerateJWT and returns its claims.
+func (ks *JWTKeySet) ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	if err := ks.parse(tokenString, claims, &claims.RegisteredClaims, GetJWTAudience()); err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeAccess || claims.ID == "" || claims.SessionID == "" ||
+		claims.Subject != strconv.Itoa(claims.UserID) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// GenerateMFAToken issues the short-lived token returned by login when the
+// user still has to present a second factor. It only proves that the password
+// was correct; its own audience keeps it from being accepted as an access
+// token.
+func (ks *JWTKeySet) GenerateMFAToken(id int, deviceName string) (string, error) {
+	return ks.Sign(&MFAClaims{
+		UserID:           id,
+		DeviceName:       deviceName,
+		TokenType:        TokenTypeMFAPending,
+		RegisteredClaims: registeredClaims(id, mfaAudience(), mfaTokenExpiration),
+	})
+}
+
+// ParseMFAToken validates a token from GenerateMFAToken and returns the user
+// ID and device name it was issued for.
+func (ks *JWTKeySet) ParseMFAToken(tokenString string) (int, string, error) {
+	claims := &MFAClaims{}
+	if err := ks.parse(tokenString, claims, &claims.RegisteredClaims, mfaAudience()); err != nil {
+		return 0, "", err
+	}
+	if claims.TokenType != TokenTypeMFAPending || claims.Subject != strconv.Itoa(claims.UserID) {
+		return 0, "", ErrInvalidToken
+	}
+	return claims.UserID, claims.DeviceName, nil
+}
+
+// parse verifies the signature of a token, only accepting the algorithms of
+// the key set, and validates its registered claims: iss must match ours and
+// aud the given audience, and exp, nbf and iat are checked allowing for the
+// clock skew.
+func (ks *JWTKeySet) parse(tokenString string, claims jwt.Claims, registered *jwt.RegisteredClaims, audience string) error {
+	parser := jwt.NewParser(jwt.WithValidMethods(ks.Algorithms()), jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenString, claims, ks.Keyfunc)
+	if err != nil || !token.Valid {
+		return ErrInvalidToken
+	}
+
+	now := time.Now()
+	skew := GetJWTClockSkew()
+	if !registered.VerifyIssuer(GetJWTIssuer(), true) ||
+		!registered.VerifyAudience(audience, true) ||
+		!registered.VerifyExpiresAt(now.Add(-skew), true) ||
+		!registered.VerifyNotBefore(now.Add(skew), false) ||
+		!registered.VerifyIssuedAt(now.Add(skew), false) {
+		return ErrInvalidToken
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return nil
 }
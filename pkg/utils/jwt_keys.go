@@ -0,0 +1,404 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// jwtKeyActivationDelay is how long a new key is only published before
+	// tokens are signed with it, so verifiers caching the JWKS and the other
+	// replicas learn about it first.
+	jwtKeyActivationDelay = 10 * time.Minute
+	// jwtKeyRetirementLeeway is added to the token lifetime before a replaced
+	// key is removed, to allow for clock skew.
+	jwtKeyRetirementLeeway = time.Minute
+	rsaKeyBits             = 2048
+)
+
+// GetJWTKeyRotationPeriod returns how often a new signing key is generated.
+// Zero disables automatic rotation.
+func GetJWTKeyRotationPeriod() time.Duration {
+	daysStr := os.Getenv("JWT_KEY_ROTATION_DAYS")
+	if daysStr == "" {
+		return time.Hour * 24 * 30
+	}
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days < 0 {
+		return time.Hour * 24 * 30
+	}
+	return time.Hour * 24 * time.Duration(days)
+}
+
+// JWTKey is a private key tokens are signed with. Its ID is sent in the kid
+// header.
+type JWTKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	CreatedAt time.Time
+	private   crypto.Signer
+}
+
+// JWTKeySet holds the keys tokens are signed and verified with. The newest
+// active key signs; every key in the set is accepted for verification and
+// published in the JWKS.
+type JWTKeySet struct {
+	dir       string
+	algorithm string
+	rotation  time.Duration
+
+	mu   sync.RWMutex
+	keys []*JWTKey // oldest first
+}
+
+// LoadJWTKeySet loads the PEM encoded RSA or Ed25519 private keys in
+// JWT_KEYS_DIR, named <kid>.pem, generating one if there is none. New keys use
+// the JWT_SIGNING_ALG algorithm, RS256 or EdDSA, and are generated every
+// JWT_KEY_ROTATION_DAYS by Rotate. Without JWT_KEYS_DIR keys only live in
+// memory, so tokens do not survive a restart and are not shared by replicas.
+func LoadJWTKeySet() (*JWTKeySet, error) {
+	ks := &JWTKeySet{
+		dir:       os.Getenv("JWT_KEYS_DIR"),
+		algorithm: os.Getenv("JWT_SIGNING_ALG"),
+		rotation:  GetJWTKeyRotationPeriod(),
+	}
+	if ks.algorithm == "" {
+		ks.algorithm = jwt.SigningMethodRS256.Alg()
+	}
+	if ks.algorithm != jwt.SigningMethodRS256.Alg() && ks.algorithm != jwt.SigningMethodEdDSA.Alg() {
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %q", ks.algorithm)
+	}
+
+	if ks.dir == "" {
+		log.Println("JWT_KEYS_DIR is not set, tokens are signed with a temporary key")
+	} else if err := os.MkdirAll(ks.dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload reads the key directory again, picking up keys added or removed by
+// other replicas.
+func (ks *JWTKeySet) Reload() error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.dir != "" {
+		keys, err := readJWTKeys(ks.dir)
+		if err != nil {
+			return err
+		}
+		ks.keys = keys
+	}
+	if len(ks.keys) == 0 {
+		return ks.addKey()
+	}
+	return nil
+}
+
+// Rotate generates a new key once the newest one is older than the rotation
+// period, and removes keys that were replaced long enough ago that no token
+// signed with them is still valid.
+func (ks *JWTKeySet) Rotate() error {
+	if ks.rotation <= 0 {
+		return nil
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(ks.keys[len(ks.keys)-1].CreatedAt) >= ks.rotation {
+		if err := ks.addKey(); err != nil {
+			return err
+		}
+	}
+
+	tokenLifetime := GetJWTExpiration()
+	if tokenLifetime < mfaTokenExpiration {
+		tokenLifetime = mfaTokenExpiration
+	}
+	for len(ks.keys) > 1 {
+		replacedAt := ks.keys[1].CreatedAt.Add(jwtKeyActivationDelay)
+		if now.Sub(replacedAt) < tokenLifetime+jwtKeyRetirementLeeway {
+			break
+		}
+		if ks.dir != "" {
+			err := os.Remove(filepath.Join(ks.dir, ks.keys[0].ID+".pem"))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		ks.keys = ks.keys[1:]
+	}
+	return nil
+}
+
+// signingKey returns the newest key that has been published for long enough,
+// or the oldest one if none has. The caller must hold the lock.
+func (ks *JWTKeySet) signingKey() *JWTKey {
+	now := time.Now()
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		if now.Sub(ks.keys[i].CreatedAt) >= jwtKeyActivationDelay {
+			return ks.keys[i]
+		}
+	}
+	return ks.keys[0]
+}
+
+// Sign signs claims with the current key, setting the kid header.
+func (ks *JWTKeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	key := ks.signingKey()
+	ks.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// Keyfunc returns the public key matching the kid header of a token, for
+// jwt.Parse. Tokens whose alg does not match the key are rejected.
+func (ks *JWTKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys {
+		if key.ID != kid {
+			continue
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return key.private.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// Algorithms returns the signing algorithms of the keys in the set.
+func (ks *JWTKeySet) Algorithms() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, key := range ks.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algorithms = append(algorithms, alg)
+		}
+	}
+	return algorithms
+}
+
+// JWK is the public part of a key, as published in the JWKS.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys tokens may be signed with.
+func (ks *JWTKeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{Use: "sig", KeyID: key.ID, Algorithm: key.Method.Alg()}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// addKey generates a key with the configured algorithm and saves it to the
+// key directory. The caller must hold the lock.
+func (ks *JWTKeySet) addKey() error {
+	var private crypto.Signer
+	var err error
+	if ks.algorithm == jwt.SigningMethodEdDSA.Alg() {
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	key, err := newJWTKey(private, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if ks.dir != "" {
+		if err := writeJWTKey(ks.dir, key.ID, der); err != nil {
+			return err
+		}
+	}
+
+	ks.keys = append(ks.keys, key)
+	return nil
+}
+
+// newJWTKey derives the ID of a generated key from its public key.
+func newJWTKey(private crypto.Signer, createdAt time.Time) (*JWTKey, error) {
+	public, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(public)
+
+	method, err := jwtSigningMethod(private)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTKey{
+		ID:        base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Method:    method,
+		CreatedAt: createdAt,
+		private:   private,
+	}, nil
+}
+
+func jwtSigningMethod(private crypto.Signer) (jwt.SigningMethod, error) {
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < rsaKeyBits {
+			return nil, fmt.Errorf("RSA keys must have at least %d bits", rsaKeyBits)
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", private)
+}
+
+// writeJWTKey saves a key atomically, so other replicas never read a partial
+// file.
+func writeJWTKey(dir, id string, der []byte) error {
+	tmp, err := os.CreateTemp(dir, ".key-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := pem.Encode(tmp, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, id+".pem"))
+}
+
+func readJWTKeys(dir string) ([]*JWTKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*JWTKey
+	for _, path := range paths {
+		key, err := readJWTKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func readJWTKey(path string) (*JWTKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	method, err := jwtSigningMethod(private)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTKey{
+		ID:        strings.TrimSuffix(filepath.Base(path), ".pem"),
+		Method:    method,
+		CreatedAt: info.ModTime(),
+		private:   private,
+	}, nil
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// htmlPolicy allows the formatting users write in Markdown and strips
+	// scripts, styles and event handlers, including from raw HTML.
+	htmlPolicy = bluemonday.UGCPolicy()
+)
+
+// RenderMarkdown converts GitHub flavored Markdown to HTML that is safe to
+// embed in a page.
+func RenderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return htmlPolicy.Sanitize(buf.String()), nil
+}
@@ -0,0 +1,289 @@
+package utils
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies supported from RFC 5545.
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxRecurrencePeriods bounds the search for the next occurrence, so rules
+// that match rarely, like the 31st of every second month, cannot loop for
+// long.
+const maxRecurrencePeriods = 1000
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// RecurrenceDay is a BYDAY entry. Ordinal selects the nth weekday of the
+// month, counting from the end when negative; 0 means every such weekday.
+type RecurrenceDay struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// Recurrence is a parsed RRULE. It supports the subset of RFC 5545 that
+// makes sense for dates without time: FREQ, INTERVAL, BYDAY (plain weekdays
+// for weekly rules, optionally with an ordinal for monthly ones),
+// BYMONTHDAY, UNTIL, COUNT and WKST.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	ByDay      []RecurrenceDay
+	ByMonthDay []int
+	Until      *time.Time
+	Count      int
+	WeekStart  time.Weekday
+}
+
+// ParseRecurrence parses a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH".
+// The "RRULE:" prefix is optional.
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+	r := &Recurrence{Interval: 1, WeekStart: time.Monday}
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" || seen[name] {
+			return nil, ErrInvalidRecurrence
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			if !slices.Contains([]string{FreqDaily, FreqWeekly, FreqMonthly, FreqYearly}, value) {
+				return nil, ErrInvalidRecurrence
+			}
+			r.Freq = value
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 || r.Interval > 999 {
+				return nil, ErrInvalidRecurrence
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 {
+				return nil, ErrInvalidRecurrence
+			}
+		case "UNTIL":
+			// Only the date matters; a time part is ignored.
+			date, _, _ := strings.Cut(value, "T")
+			until, err := time.Parse("20060102", date)
+			if err != nil {
+				return nil, ErrInvalidRecurrence
+			}
+			r.Until = &until
+		case "WKST":
+			weekday, ok := rruleWeekdays[value]
+			if !ok {
+				return nil, ErrInvalidRecurrence
+			}
+			r.WeekStart = weekday
+		case "BYDAY":
+			for _, entry := range strings.Split(value, ",") {
+				day, err := parseRecurrenceDay(entry)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, entry := range strings.Split(value, ",") {
+				day, err := strconv.Atoi(entry)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return nil, ErrInvalidRecurrence
+				}
+				r.ByMonthDay = append(r.ByMonthDay, day)
+			}
+		default:
+			return nil, ErrInvalidRecurrence
+		}
+	}
+
+	if r.Freq == "" || (r.Count > 0 && r.Until != nil) {
+		return nil, ErrInvalidRecurrence
+	}
+	if len(r.ByDay) > 0 && r.Freq != FreqWeekly && r.Freq != FreqMonthly {
+		return nil, ErrInvalidRecurrence
+	}
+	if len(r.ByMonthDay) > 0 && (r.Freq != FreqMonthly || len(r.ByDay) > 0) {
+		return nil, ErrInvalidRecurrence
+	}
+	for _, day := range r.ByDay {
+		if day.Ordinal != 0 && r.Freq != FreqMonthly {
+			return nil, ErrInvalidRecurrence
+		}
+	}
+
+	return r, nil
+}
+
+func parseRecurrenceDay(entry string) (RecurrenceDay, error) {
+	if len(entry) < 2 {
+		return RecurrenceDay{}, ErrInvalidRecurrence
+	}
+	weekday, ok := rruleWeekdays[entry[len(entry)-2:]]
+	if !ok {
+		return RecurrenceDay{}, ErrInvalidRecurrence
+	}
+	day := RecurrenceDay{Weekday: weekday}
+	if ordinal := entry[:len(entry)-2]; ordinal != "" {
+		n, err := strconv.Atoi(ordinal)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return RecurrenceDay{}, ErrInvalidRecurrence
+		}
+		day.Ordinal = n
+	}
+	return day, nil
+}
+
+// Next returns the first occurrence strictly after the given date of the
+// series that starts on start. Both are calendar dates; the time of day is
+// ignored. It returns false when the series ends before, through UNTIL, or
+// no occurrence is found within a reasonable number of periods. COUNT is
+// left to the caller, which knows how many occurrences have passed.
+func (r *Recurrence) Next(start, after time.Time) (time.Time, bool) {
+	start = dateOnly(start)
+	after = dateOnly(after)
+
+	first := r.firstPeriod(start, after)
+	for period := first; period < first+maxRecurrencePeriods; period++ {
+		for _, candidate := range r.occurrencesInPeriod(start, period) {
+			if candidate.Before(start) || !candidate.After(after) {
+				continue
+			}
+			if r.Until != nil && candidate.After(*r.Until) {
+				return time.Time{}, false
+			}
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// firstPeriod skips the periods that end before after, so a long running
+// daily series does not have to be walked from its start.
+func (r *Recurrence) firstPeriod(start, after time.Time) int {
+	if !after.After(start) {
+		return 0
+	}
+	var elapsed int
+	switch r.Freq {
+	case FreqDaily:
+		elapsed = int(after.Sub(start).Hours() / 24)
+	case FreqWeekly:
+		elapsed = int(after.Sub(r.weekOf(start)).Hours() / 24 / 7)
+	case FreqMonthly:
+		elapsed = (after.Year()-start.Year())*12 + int(after.Month()-start.Month())
+	case FreqYearly:
+		elapsed = after.Year() - start.Year()
+	}
+	return max(elapsed/r.Interval-1, 0)
+}
+
+// occurrencesInPeriod lists the dates of the nth period of the series in
+// calendar order.
+func (r *Recurrence) occurrencesInPeriod(start time.Time, period int) []time.Time {
+	step := period * r.Interval
+
+	switch r.Freq {
+	case FreqDaily:
+		return []time.Time{start.AddDate(0, 0, step)}
+
+	case FreqWeekly:
+		weekStart := r.weekOf(start).AddDate(0, 0, 7*step)
+		if len(r.ByDay) == 0 {
+			return []time.Time{weekStart.AddDate(0, 0, r.daysFromWeekStart(start.Weekday()))}
+		}
+		var dates []time.Time
+		for offset := 0; offset < 7; offset++ {
+			date := weekStart.AddDate(0, 0, offset)
+			if slices.ContainsFunc(r.ByDay, func(d RecurrenceDay) bool { return d.Weekday == date.Weekday() }) {
+				dates = append(dates, date)
+			}
+		}
+		return dates
+
+	case FreqMonthly:
+		month := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		daysInMonth := month.AddDate(0, 1, -1).Day()
+		var days []int
+		switch {
+		case len(r.ByDay) > 0:
+			for day := 1; day <= daysInMonth; day++ {
+				weekday := month.AddDate(0, 0, day-1).Weekday()
+				fromStart := (day-1)/7 + 1
+				fromEnd := -((daysInMonth-day)/7 + 1)
+				for _, d := range r.ByDay {
+					if d.Weekday == weekday && (d.Ordinal == 0 || d.Ordinal == fromStart || d.Ordinal == fromEnd) {
+						days = append(days, day)
+						break
+					}
+				}
+			}
+		case len(r.ByMonthDay) > 0:
+			for _, day := range r.ByMonthDay {
+				if day < 0 {
+					day = daysInMonth + day + 1
+				}
+				if day >= 1 && day <= daysInMonth && !slices.Contains(days, day) {
+					days = append(days, day)
+				}
+			}
+			slices.Sort(days)
+		default:
+			// Months without the start day, like February for the 31st,
+			// are skipped as RFC 5545 requires.
+			if start.Day() <= daysInMonth {
+				days = []int{start.Day()}
+			}
+		}
+		dates := make([]time.Time, 0, len(days))
+		for _, day := range days {
+			dates = append(dates, month.AddDate(0, 0, day-1))
+		}
+		return dates
+
+	case FreqYearly:
+		date := time.Date(start.Year()+step, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		// February 29 only occurs in leap years.
+		if date.Day() != start.Day() {
+			return nil
+		}
+		return []time.Time{date}
+	}
+
+	return nil
+}
+
+func (r *Recurrence) weekOf(date time.Time) time.Time {
+	return date.AddDate(0, 0, -r.daysFromWeekStart(date.Weekday()))
+}
+
+func (r *Recurrence) daysFromWeekStart(weekday time.Weekday) int {
+	return (int(weekday) - int(r.WeekStart) + 7) % 7
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
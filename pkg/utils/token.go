@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// PersonalAccessTokenPrefix marks personal access tokens so they can be told
+// apart from JWTs, and found by secret scanners.
+const PersonalAccessTokenPrefix = "sylcot_pat_"
+
+// InviteCodePrefix marks invite codes the same way.
+const InviteCodePrefix = "sylcot_inv_"
+
+// GenerateOpaqueToken returns a URL-safe random token with 256 bits of entropy.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a token, which is what gets
+// persisted so that a database leak does not expose usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GeneratePersonalAccessToken returns a new random personal access token.
+func GeneratePersonalAccessToken() (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+// GenerateInviteCode returns a new random registration invite code.
+func GenerateInviteCode() (string, error) {
+	code, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return InviteCodePrefix + code, nil
+}